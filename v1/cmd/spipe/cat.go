@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func runCat(args []string, e *env) error {
+	fs := e.flags("cat", "[--sep STR] [--continue-on-error] [--decompress] [FILE...]")
+
+	sep := fs.String("sep", "", "write `STR` between inputs, escapes such as \\n are interpreted")
+	keepGoing := fs.Bool("continue-on-error", false, "skip inputs that cannot be read rather than stopping")
+	decompress := fs.Bool("decompress", false, "decompress gzip and bzip2 inputs")
+
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	separator, err := unescape(*sep)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+
+	var inputs []io.ReadCloser
+	var names []string
+
+	for i, name := range args {
+		if i > 0 && separator != "" {
+			inputs = append(inputs, ioutil.NopCloser(strings.NewReader(separator)))
+			names = append(names, "separator")
+		}
+
+		in := openInput(name, e.stdin, *decompress)
+
+		// Inputs are decompressed before being wrapped, so a corrupt
+		// compressed input is skipped like any other failing input.
+		if *keepGoing {
+			in = &skipping{ReadCloser: in, name: name, env: e}
+		}
+
+		inputs = append(inputs, in)
+		names = append(names, name)
+	}
+
+	r := spipe.NewMultiReadCloser(inputs...).
+		CloseImmediately(true).
+		Names(names...)
+
+	_, err = io.Copy(e.stdout, r)
+
+	return spipe.NewMultiErrorBuilder().Add(err, r.Close()).Build()
+}
+
+// openInput returns a reader for the named input, "-" being stdin,
+// decompressing it if asked to.  Files are opened lazily so only one is held
+// open at a time.
+func openInput(name string, stdin io.Reader, decompress bool) io.ReadCloser {
+	in := spipe.NewLazyReadCloser(func() (io.ReadCloser, error) {
+		if name == "-" {
+			return ioutil.NopCloser(stdin), nil
+		}
+
+		return os.Open(name)
+	})
+
+	if decompress {
+		in = in.Decompress()
+	}
+
+	return in
+}
+
+// skipping ends an input at its first error, reporting the error as tolerated.
+type skipping struct {
+	io.ReadCloser
+	name string
+	env  *env
+	err  bool
+}
+
+func (s *skipping) Read(p []byte) (int, error) {
+	if s.err {
+		return 0, io.EOF
+	}
+
+	n, err := s.ReadCloser.Read(p)
+
+	if err != nil && err != io.EOF {
+		s.err = true
+		s.env.tolerate(fmt.Errorf("read %s: %w", s.name, err))
+
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+func (s *skipping) Close() error {
+	err := s.ReadCloser.Close()
+
+	if err != nil {
+		s.env.tolerate(fmt.Errorf("close %s: %w", s.name, err))
+	}
+
+	return nil
+}
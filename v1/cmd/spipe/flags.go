@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// stringList is a flag that may be given more than once.
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// sizeFlag is a byte count flag that accepts a K, M or G suffix for powers of
+// 1024.
+type sizeFlag int64
+
+func (s *sizeFlag) String() string {
+	return strconv.FormatInt(int64(*s), 10)
+}
+
+func (s *sizeFlag) Set(v string) error {
+	if v == "" {
+		return usageError("size must be a positive number of bytes")
+	}
+
+	scale := int64(1)
+
+	switch strings.ToUpper(v[len(v)-1:]) {
+	case "K":
+		scale = 1 << 10
+	case "M":
+		scale = 1 << 20
+	case "G":
+		scale = 1 << 30
+	}
+
+	if scale > 1 {
+		v = v[:len(v)-1]
+	}
+
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 1 {
+		return usageError("size must be a positive number of bytes")
+	}
+
+	*s = sizeFlag(n * scale)
+	return nil
+}
+
+// unescape interprets Go string escapes such as \n and \t in a flag value.
+func unescape(v string) (string, error) {
+	out, err := strconv.Unquote(`"` + strings.ReplaceAll(v, `"`, `\"`) + `"`)
+	if err != nil {
+		return "", usageError("invalid escape in " + strconv.Quote(v))
+	}
+
+	return out, nil
+}
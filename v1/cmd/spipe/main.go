@@ -0,0 +1,205 @@
+// Command spipe exposes the split-pipe library from the shell.
+//
+//	spipe tee [-a] [--ignore-secondary-errors] [-o FILE]... [--exec CMD]... [FILE...]
+//	spipe cat [--sep STR] [--continue-on-error] [--decompress] [FILE...]
+//	spipe split -b SIZE [-m MANIFEST] PATTERN
+//	spipe join [-m MANIFEST] PATTERN
+//
+// The exit status is 0 on success, 1 if the command failed, 2 if it was used
+// incorrectly, and 3 if it completed but tolerated errors along the way, such
+// as a failed secondary output with --ignore-secondary-errors or an unreadable
+// input with --continue-on-error.  Every error is reported on stderr.
+//
+// Each --exec command is run with sh -c and fed a copy of stdin, like process
+// substitution in a shell.  A command that exits with a non-zero status is
+// reported as a failed output.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// Exit statuses.
+const (
+	exitOK      = 0
+	exitFailed  = 1
+	exitUsage   = 2
+	exitPartial = 3
+)
+
+const usage = `usage: spipe <command> [options]
+
+commands:
+  tee    copy stdin to stdout and to every given output
+  cat    concatenate inputs to stdout
+  split  cut stdin into numbered part files
+  join   reassemble part files to stdout
+
+Run 'spipe <command> -h' for the options of a command.
+`
+
+// command runs a subcommand with its arguments, recording tolerated errors in
+// the given env.
+type command func(args []string, env *env) error
+
+var commands = map[string]command{
+	"tee":   runTee,
+	"cat":   runCat,
+	"split": runSplit,
+	"join":  runJoin,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// env holds the standard streams of a command and the errors it has tolerated.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	// tolerated collects errors that did not stop the command.
+	tolerated spipe.MultiErrorBuilder
+}
+
+// tolerate records an error that did not stop the command.
+func (e *env) tolerate(err error) {
+	if err != nil {
+		e.tolerated.Add(err)
+	}
+}
+
+// flags returns a flag set for the named subcommand that reports to stderr.
+func (e *env) flags(name, synopsis string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "usage: spipe %s %s\n\noptions:\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+
+	return fs
+}
+
+// errBadFlags is returned by parse for flags the flag set has already reported
+// as invalid.
+var errBadFlags = errors.New("invalid flags")
+
+// parse parses the flags of a command and returns its positional arguments.
+// Unlike FlagSet.Parse, flags may follow positional arguments.  Everything
+// after a "--" argument is positional.
+func parse(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+
+	for {
+		if err := fs.Parse(args); err != nil {
+			if err == flag.ErrHelp {
+				return nil, err
+			}
+
+			return nil, errBadFlags
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		if at := len(args) - len(rest); at > 0 && args[at-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// usageError is returned by a command that was used incorrectly.
+type usageError string
+
+func (u usageError) Error() string {
+	return string(u)
+}
+
+// run runs the command line given by args and returns the exit status.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+
+	if args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "spipe: unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+
+	e := &env{
+		stdin:     stdin,
+		stdout:    stdout,
+		stderr:    stderr,
+		tolerated: spipe.NewMultiErrorBuilder(),
+	}
+
+	err := cmd(args[1:], e)
+
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+
+	if err == errBadFlags {
+		return exitUsage
+	}
+
+	if _, ok := err.(usageError); ok {
+		fmt.Fprintf(stderr, "spipe %s: %s\n", args[0], err)
+		return exitUsage
+	}
+
+	if err != nil {
+		report(stderr, args[0], err)
+		report(stderr, args[0], e.tolerated.Build())
+
+		return exitFailed
+	}
+
+	if tolerated := e.tolerated.Build(); tolerated != nil {
+		report(stderr, args[0], tolerated)
+		return exitPartial
+	}
+
+	return exitOK
+}
+
+// report writes every error wrapped by err to stderr, one per line.
+func report(stderr io.Writer, name string, err error) {
+	if err == nil {
+		return
+	}
+
+	if m, ok := err.(spipe.MultiError); ok {
+		for _, e := range m.Errors() {
+			fmt.Fprintf(stderr, "spipe %s: %s\n", name, e)
+		}
+
+		if m.Omitted() > 0 {
+			fmt.Fprintf(stderr, "spipe %s: %d more errors omitted\n", name, m.Omitted())
+		}
+
+		return
+	}
+
+	fmt.Fprintf(stderr, "spipe %s: %s\n", name, err)
+}
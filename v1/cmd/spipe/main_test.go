@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// runSpipe runs the command line with the given stdin, returning the exit
+// status and what was written to stdout and stderr.
+func runSpipe(stdin string, args ...string) (int, string, string) {
+	stdout, stderr := new(bytes.Buffer), new(bytes.Buffer)
+	status := run(args, strings.NewReader(stdin), stdout, stderr)
+
+	return status, stdout.String(), stderr.String()
+}
+
+func tempDir() string {
+	dir, _ := ioutil.TempDir("", "spipe-cmd")
+	return dir
+}
+
+func readFile(name string) string {
+	out, _ := ioutil.ReadFile(name)
+	return string(out)
+}
+
+func TestRun(t *testing.T) {
+	Convey("spipe", t, func() {
+		dir := tempDir()
+		Reset(func() { _ = os.RemoveAll(dir) })
+
+		a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+		missing := filepath.Join(dir, "missing", "c.log")
+
+		Convey("usage", func() {
+			status, _, stderr := runSpipe("")
+			So(status, ShouldEqual, exitUsage)
+			So(stderr, ShouldContainSubstring, "commands:")
+
+			status, _, _ = runSpipe("", "nope")
+			So(status, ShouldEqual, exitUsage)
+
+			status, _, _ = runSpipe("", "tee", "--nope")
+			So(status, ShouldEqual, exitUsage)
+
+			status, _, _ = runSpipe("", "cat", "-h")
+			So(status, ShouldEqual, exitOK)
+		})
+
+		Convey("tee", func() {
+			status, stdout, stderr := runSpipe("hello\n", "tee", "-o", a, b)
+
+			So(status, ShouldEqual, exitOK)
+			So(stderr, ShouldBeEmpty)
+			So(stdout, ShouldEqual, "hello\n")
+			So(readFile(a), ShouldEqual, "hello\n")
+			So(readFile(b), ShouldEqual, "hello\n")
+
+			Convey("append", func() {
+				status, _, _ := runSpipe("again\n", "tee", "-a", "-o", a)
+				So(status, ShouldEqual, exitOK)
+				So(readFile(a), ShouldEqual, "hello\nagain\n")
+			})
+
+			Convey("failing output", func() {
+				status, stdout, stderr := runSpipe("hello\n", "tee", "-o", a, "-o", missing)
+
+				So(status, ShouldEqual, exitFailed)
+				So(stdout, ShouldBeEmpty)
+				So(stderr, ShouldContainSubstring, missing)
+			})
+
+			Convey("ignored failing output", func() {
+				status, stdout, stderr := runSpipe("hello\n", "tee", "-o", missing, "-o", a, "--ignore-secondary-errors")
+
+				So(status, ShouldEqual, exitPartial)
+				So(stdout, ShouldEqual, "hello\n")
+				So(readFile(a), ShouldEqual, "hello\n")
+				So(stderr, ShouldContainSubstring, missing)
+			})
+
+			execConvey := Convey
+			if _, err := exec.LookPath("sh"); err != nil {
+				execConvey = SkipConvey
+			}
+
+			execConvey("exec", func() {
+				input := "info a\nERROR b\ninfo c\n"
+				status, stdout, stderr := runSpipe(input, "tee",
+					"--exec", "gzip > "+b,
+					"--exec", "grep ERROR >&2",
+					"-o", a)
+
+				So(status, ShouldEqual, exitOK)
+				So(stdout, ShouldEqual, input)
+				So(stderr, ShouldEqual, "ERROR b\n")
+				So(readFile(a), ShouldEqual, input)
+
+				r, err := gzip.NewReader(strings.NewReader(readFile(b)))
+				So(err, ShouldBeNil)
+				unzipped, _ := ioutil.ReadAll(r)
+				So(string(unzipped), ShouldEqual, input)
+
+				Convey("exit status", func() {
+					status, stdout, stderr := runSpipe(input, "tee",
+						"--exec", "cat >/dev/null; exit 4",
+						"--exec", "cat >/dev/null")
+
+					So(status, ShouldEqual, exitFailed)
+					So(stdout, ShouldEqual, input)
+					So(stderr, ShouldContainSubstring, "exit status 4")
+				})
+
+				Convey("ignored exit status", func() {
+					status, stdout, stderr := runSpipe(input, "tee",
+						"--exec", "cat >/dev/null; exit 4",
+						"--ignore-secondary-errors")
+
+					So(status, ShouldEqual, exitPartial)
+					So(stdout, ShouldEqual, input)
+					So(stderr, ShouldContainSubstring, "exit status 4")
+				})
+			})
+		})
+
+		Convey("cat", func() {
+			_ = ioutil.WriteFile(a, []byte("one"), 0666)
+			_ = ioutil.WriteFile(b, []byte("two"), 0666)
+
+			status, stdout, _ := runSpipe("stdin", "cat", a, "-", b)
+			So(status, ShouldEqual, exitOK)
+			So(stdout, ShouldEqual, "onestdintwo")
+
+			status, stdout, _ = runSpipe("stdin", "cat")
+			So(status, ShouldEqual, exitOK)
+			So(stdout, ShouldEqual, "stdin")
+
+			Convey("separators", func() {
+				status, stdout, _ := runSpipe("", "cat", "--sep", `\n`, a, b)
+				So(status, ShouldEqual, exitOK)
+				So(stdout, ShouldEqual, "one\ntwo")
+
+				status, _, _ = runSpipe("", "cat", "--sep", `\q`, a, b)
+				So(status, ShouldEqual, exitUsage)
+			})
+
+			Convey("failing input", func() {
+				status, stdout, stderr := runSpipe("", "cat", a, missing, b)
+
+				So(status, ShouldEqual, exitFailed)
+				So(stdout, ShouldEqual, "one")
+				So(stderr, ShouldContainSubstring, missing)
+			})
+
+			Convey("continue on error", func() {
+				status, stdout, stderr := runSpipe("", "cat", a, missing, b, "--continue-on-error")
+
+				So(status, ShouldEqual, exitPartial)
+				So(stdout, ShouldEqual, "onetwo")
+				So(stderr, ShouldContainSubstring, missing)
+			})
+
+			Convey("decompress", func() {
+				gz := new(bytes.Buffer)
+				w := gzip.NewWriter(gz)
+				_, _ = w.Write([]byte("zipped"))
+				_ = w.Close()
+				_ = ioutil.WriteFile(b, gz.Bytes(), 0666)
+
+				status, stdout, _ := runSpipe("", "cat", "--decompress", a, b)
+				So(status, ShouldEqual, exitOK)
+				So(stdout, ShouldEqual, "onezipped")
+
+				Convey("truncated input skipped", func() {
+					_ = ioutil.WriteFile(b, gz.Bytes()[:gz.Len()-4], 0666)
+
+					status, stdout, stderr := runSpipe("", "cat", "--decompress", "--continue-on-error", b, a)
+					So(status, ShouldEqual, exitPartial)
+					So(stdout, ShouldEndWith, "one")
+					So(stderr, ShouldContainSubstring, b)
+				})
+			})
+		})
+
+		Convey("split and join", func() {
+			input := strings.Repeat("0123456789", 10)
+			pattern := filepath.Join(dir, "part.%03d")
+			manifest := filepath.Join(dir, "manifest.json")
+
+			status, _, stderr := runSpipe(input, "split", "-b", "32", "-m", manifest, pattern)
+			So(status, ShouldEqual, exitOK)
+			So(stderr, ShouldBeEmpty)
+			So(readFile(filepath.Join(dir, "part.003")), ShouldEqual, input[96:])
+
+			status, stdout, _ := runSpipe("", "join", pattern)
+			So(status, ShouldEqual, exitOK)
+			So(stdout, ShouldEqual, input)
+
+			status, stdout, _ = runSpipe("", "join", "-m", manifest, pattern)
+			So(status, ShouldEqual, exitOK)
+			So(stdout, ShouldEqual, input)
+
+			Convey("corrupt part", func() {
+				_ = ioutil.WriteFile(filepath.Join(dir, "part.001"), []byte(strings.Repeat("x", 32)), 0666)
+
+				status, _, stderr := runSpipe("", "join", "-m", manifest, pattern)
+				So(status, ShouldEqual, exitFailed)
+				So(stderr, ShouldContainSubstring, "checksum mismatch")
+			})
+
+			Convey("size suffixes", func() {
+				status, _, _ := runSpipe(input, "split", "-b", "1K", pattern)
+				So(status, ShouldEqual, exitOK)
+				So(readFile(filepath.Join(dir, "part.000")), ShouldEqual, input)
+			})
+
+			Convey("fewer parts than before", func() {
+				status, _, _ := runSpipe(input[:40], "split", "-b", "32", pattern)
+				So(status, ShouldEqual, exitOK)
+
+				_, err := os.Stat(filepath.Join(dir, "part.002"))
+				So(os.IsNotExist(err), ShouldBeTrue)
+
+				status, stdout, _ := runSpipe("", "join", pattern)
+				So(status, ShouldEqual, exitOK)
+				So(stdout, ShouldEqual, input[:40])
+			})
+
+			Convey("bad arguments", func() {
+				status, _, _ := runSpipe(input, "split", pattern)
+				So(status, ShouldEqual, exitUsage)
+
+				status, _, _ = runSpipe(input, "split", "-b", "1X", pattern)
+				So(status, ShouldEqual, exitUsage)
+
+				status, _, _ = runSpipe(input, "split", "-b", "10", filepath.Join(dir, "part"))
+				So(status, ShouldEqual, exitUsage)
+
+				status, _, _ = runSpipe("", "join")
+				So(status, ShouldEqual, exitUsage)
+			})
+		})
+	})
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func runSplit(args []string, e *env) error {
+	fs := e.flags("split", "-b SIZE [-m MANIFEST] PATTERN")
+
+	var size sizeFlag
+	fs.Var(&size, "b", "write parts of at most `SIZE` bytes, K, M and G suffixes are accepted")
+	manifest := fs.String("m", "", "write a manifest of the parts' sizes and checksums to `FILE`")
+
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	pattern, err := partPattern(args)
+	if err != nil {
+		return err
+	}
+
+	if size == 0 {
+		return usageError("a part size must be given with -b")
+	}
+
+	var m *os.File
+	if *manifest != "" {
+		if m, err = os.Create(*manifest); err != nil {
+			return err
+		}
+	}
+
+	var mw io.Writer
+	if m != nil {
+		mw = m
+	}
+
+	w := spipe.NewChunkWriteCloser(int64(size), spipe.FileParts(pattern), mw)
+
+	_, err = io.Copy(w, e.stdin)
+	errs := spipe.NewMultiErrorBuilder().Add(err, w.Close())
+
+	if m != nil {
+		errs.Add(m.Close())
+	}
+
+	if err = errs.Build(); err != nil {
+		return err
+	}
+
+	return removeParts(pattern, len(w.Manifest().Parts))
+}
+
+// removeParts removes the parts named by pattern from the given index on,
+// stopping at the first that does not exist, so parts left over from an earlier
+// split into more parts are not picked up by join.
+func removeParts(pattern string, from int) error {
+	for i := from; ; i++ {
+		if err := os.Remove(fmt.Sprintf(pattern, i)); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+func runJoin(args []string, e *env) error {
+	fs := e.flags("join", "[-m MANIFEST] PATTERN")
+
+	manifest := fs.String("m", "", "verify the parts against the manifest in `FILE`")
+
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	pattern, err := partPattern(args)
+	if err != nil {
+		return err
+	}
+
+	var r spipe.MultiReadCloser
+
+	if *manifest == "" {
+		if r, err = spipe.OpenFileParts(pattern); err != nil {
+			return err
+		}
+	} else {
+		if r, err = openManifestParts(*manifest, pattern); err != nil {
+			return err
+		}
+	}
+
+	_, err = io.Copy(e.stdout, r)
+
+	return spipe.NewMultiErrorBuilder().Add(err, r.Close()).Build()
+}
+
+// openManifestParts returns a reader over the parts named by pattern that
+// checks each against the manifest in the named file.
+func openManifestParts(manifest, pattern string) (spipe.MultiReadCloser, error) {
+	f, err := os.Open(manifest)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	m, err := spipe.ReadManifest(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading manifest %s: %w", manifest, err)
+	}
+
+	return spipe.NewChunkReadCloser(m, func(index int) (io.ReadCloser, error) {
+		return os.Open(fmt.Sprintf(pattern, index))
+	}), nil
+}
+
+// partPattern returns the single part file name pattern argument, which must
+// contain a verb for the part index such as %03d.
+func partPattern(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", usageError("a single part file name pattern must be given")
+	}
+
+	if !strings.Contains(args[0], "%") {
+		return "", usageError("the pattern must contain a verb for the part index, such as part.%03d")
+	}
+
+	return args[0], nil
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func runTee(args []string, e *env) error {
+	fs := e.flags("tee", "[-a] [--ignore-secondary-errors] [-o FILE]... [--exec CMD]... [FILE...]")
+
+	var files, commands stringList
+	fs.Var(&files, "o", "write a copy of stdin to `FILE`, may be repeated")
+	fs.Var(&commands, "exec", "pipe a copy of stdin to the shell command `CMD`, may be repeated")
+	appendTo := fs.Bool("a", false, "append to files rather than truncating them")
+	ignore := fs.Bool("ignore-secondary-errors", false, "keep copying to stdout when an output fails")
+
+	args, err := parse(fs, args)
+	if err != nil {
+		return err
+	}
+
+	files = append(files, args...)
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if *appendTo {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+
+	t := newTee(e, *ignore)
+
+	for _, name := range files {
+		f, err := os.OpenFile(name, flags, 0666)
+		if err != nil {
+			if err = t.failed(err); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		t.add(name, f)
+	}
+
+	// Commands write to the same stdout and stderr as the tee itself.
+	e.stdout, e.stderr = shared(e.stdout), shared(e.stderr)
+
+	for _, line := range commands {
+		cmd := exec.Command("sh", "-c", line)
+		cmd.Stdout, cmd.Stderr = e.stdout, e.stderr
+
+		w, err := spipe.NewCommandWriteCloser(cmd)
+		if err != nil {
+			if err = t.failed(fmt.Errorf("exec %s: %w", line, err)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		t.add(line, w)
+	}
+
+	return t.run()
+}
+
+// tee copies stdin to stdout and a set of secondary outputs.
+type tee struct {
+	env    *env
+	ignore bool
+	names  []string
+	outs   []io.WriteCloser
+}
+
+func newTee(e *env, ignore bool) *tee {
+	return &tee{env: e, ignore: ignore, names: []string{"stdout"}}
+}
+
+// add adds a secondary output.
+func (t *tee) add(name string, out io.WriteCloser) {
+	if t.ignore {
+		out = &watched{WriteCloser: out, name: name, env: t.env}
+	}
+
+	t.names = append(t.names, name)
+	t.outs = append(t.outs, out)
+}
+
+// failed handles a secondary output that could not be opened.  The error is
+// tolerated if secondary errors are ignored, otherwise the outputs opened so far
+// are closed and the error is returned.
+func (t *tee) failed(err error) error {
+	if t.ignore {
+		t.env.tolerate(err)
+		return nil
+	}
+
+	errs := spipe.NewMultiErrorBuilder().Add(err)
+
+	for _, out := range t.outs {
+		errs.Add(out.Close())
+	}
+
+	return errs.Build()
+}
+
+// run copies stdin to every output and closes them.
+func (t *tee) run() error {
+	w := spipe.NewSplitWriteCloser(nopWriteCloser{t.env.stdout}, t.outs...).
+		IgnoreErrors(t.ignore).
+		Names(t.names...)
+
+	_, err := io.Copy(w, t.env.stdin)
+
+	return spipe.NewMultiErrorBuilder().Add(err, w.Close()).Build()
+}
+
+// watched reports the first error from a secondary output whose errors are
+// otherwise ignored.
+type watched struct {
+	io.WriteCloser
+	name   string
+	env    *env
+	failed bool
+}
+
+func (w *watched) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+
+	if err != nil && !w.failed {
+		w.failed = true
+		w.env.tolerate(fmt.Errorf("write %s: %w", w.name, err))
+	}
+
+	return n, err
+}
+
+func (w *watched) Close() error {
+	err := w.WriteCloser.Close()
+
+	if err != nil {
+		w.env.tolerate(fmt.Errorf("close %s: %w", w.name, err))
+	}
+
+	return err
+}
+
+// shared returns a writer that may be written to from several goroutines, as
+// the output of a command is copied from its own goroutine unless it is a file.
+func shared(w io.Writer) io.Writer {
+	if _, ok := w.(*os.File); ok {
+		return w
+	}
+
+	return &syncWriter{w: w}
+}
+
+// syncWriter serialises writes to a writer.
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// nopWriteCloser adds a Close method that does nothing to a writer, so stdout
+// is never closed.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,220 @@
+package spipe
+
+import (
+	"context"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncWriteCloser defines an io.WriteCloser implementation that hands writes
+// off to a goroutine so that a slow or rate limited output does not hold up the
+// caller.
+//
+// Each write is copied into a bounded queue.  When the queue is full, writes
+// block until there is room, or are discarded if DropWhenFull is set.
+//
+// An AsyncWriteCloser is intended to be used as a secondary output of a split
+// writer, so that limiting or stalling the secondary does not throttle the
+// primary.
+//
+// Write must not be called concurrently.
+type AsyncWriteCloser interface {
+	io.WriteCloser
+
+	// QueueSize sets the number of writes that may be queued before further
+	// writes block or are dropped.  Has no effect once the first write has been
+	// made.  Defaults to DefaultQueueSize.
+	QueueSize(int) AsyncWriteCloser
+
+	// DropWhenFull sets whether writes made while the queue is full are
+	// discarded rather than blocking.  Dropped writes are reported as
+	// successful and counted by Dropped.
+	DropWhenFull(bool) AsyncWriteCloser
+
+	// Limit caps the rate at which bytes are written to the output.  A nil
+	// limiter removes the cap.
+	Limit(*Limiter) AsyncWriteCloser
+
+	// Context sets the context used to cancel waits on the limiter.  Defaults
+	// to context.Background().
+	Context(context.Context) AsyncWriteCloser
+
+	// Flush waits for every queued write to be written to the output.
+	Flush() error
+
+	// Dropped returns the number of bytes discarded because the queue was full.
+	Dropped() int64
+}
+
+// NewAsyncWriteCloser constructs a new AsyncWriteCloser instance that writes to
+// the given output from a background goroutine.
+//
+// Errors returned by the output are held and returned from the next call to
+// Write, Flush or Close.  Once the output has failed, anything still queued,
+// and anything written afterwards, is discarded.
+func NewAsyncWriteCloser(out io.WriteCloser) AsyncWriteCloser {
+	return &asyncWriteCloser{
+		out:       out,
+		queueSize: DefaultQueueSize,
+	}
+}
+
+type asyncWriteCloser struct {
+	out       io.WriteCloser
+	queue     chan []byte
+	queueSize int
+	drop      bool
+	limiter   *Limiter
+	ctx       context.Context
+	started   bool
+	closed    bool
+	dropped   int64
+
+	// pending tracks writes that have been queued but not yet written or
+	// discarded.
+	pending sync.WaitGroup
+	worker  sync.WaitGroup
+
+	// mu guards err.
+	mu  sync.Mutex
+	err error
+}
+
+// Write queues a copy of the given bytes to be written to the output.
+//
+// The returned byte count is always len(p) unless an earlier write to the
+// output has failed, in which case that failure is returned.
+func (a *asyncWriteCloser) Write(p []byte) (int, error) {
+	if a.closed {
+		return 0, os.ErrClosed
+	}
+
+	if err := a.failure(); err != nil {
+		return 0, err
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	a.start()
+	a.pending.Add(1)
+
+	rec := append([]byte(nil), p...)
+
+	if !a.drop {
+		a.queue <- rec
+		return len(p), nil
+	}
+
+	select {
+	case a.queue <- rec:
+	default:
+		a.pending.Done()
+		atomic.AddInt64(&a.dropped, int64(len(p)))
+	}
+
+	return len(p), nil
+}
+
+func (a *asyncWriteCloser) Flush() error {
+	a.pending.Wait()
+	return a.failure()
+}
+
+// Close waits for every queued write to be written and then closes the output.
+func (a *asyncWriteCloser) Close() error {
+	if a.closed {
+		return nil
+	}
+
+	a.closed = true
+
+	if a.started {
+		close(a.queue)
+		a.worker.Wait()
+	}
+
+	errs := NewMultiErrorBuilder()
+	errs.Add(a.failure(), a.out.Close())
+
+	return errs.Build()
+}
+
+func (a *asyncWriteCloser) QueueSize(n int) AsyncWriteCloser {
+	if n < 1 {
+		n = 1
+	}
+
+	a.queueSize = n
+	return a
+}
+
+func (a *asyncWriteCloser) DropWhenFull(b bool) AsyncWriteCloser {
+	a.drop = b
+	return a
+}
+
+func (a *asyncWriteCloser) Limit(limiter *Limiter) AsyncWriteCloser {
+	a.limiter = limiter
+	return a
+}
+
+func (a *asyncWriteCloser) Context(ctx context.Context) AsyncWriteCloser {
+	a.ctx = ctx
+	return a
+}
+
+func (a *asyncWriteCloser) Dropped() int64 {
+	return atomic.LoadInt64(&a.dropped)
+}
+
+func (a *asyncWriteCloser) start() {
+	if a.started {
+		return
+	}
+
+	a.started = true
+	a.queue = make(chan []byte, a.queueSize)
+	a.worker.Add(1)
+
+	go a.work()
+}
+
+func (a *asyncWriteCloser) work() {
+	defer a.worker.Done()
+
+	for rec := range a.queue {
+		if a.failure() == nil {
+			if err := a.write(rec); err != nil {
+				a.fail(err)
+			}
+		}
+
+		a.pending.Done()
+	}
+}
+
+func (a *asyncWriteCloser) write(rec []byte) error {
+	if a.limiter != nil {
+		if err := a.limiter.WaitN(orBackground(a.ctx), len(rec)); err != nil {
+			return err
+		}
+	}
+
+	return writeRecordTo(a.out, rec)
+}
+
+func (a *asyncWriteCloser) failure() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.err
+}
+
+func (a *asyncWriteCloser) fail(err error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.err = err
+}
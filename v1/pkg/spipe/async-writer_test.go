@@ -0,0 +1,132 @@
+package spipe_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestAsyncWriteCloser_Write(t *testing.T) {
+	Convey("AsyncWriteCloser.Write", t, func() {
+		Convey("writes in order", func() {
+			out := new(WriteCloser)
+			test := spipe.NewAsyncWriteCloser(out)
+
+			for _, s := range []string{"hello", " ", "world"} {
+				n, err := test.Write([]byte(s))
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, len(s))
+			}
+
+			So(test.Flush(), ShouldBeNil)
+			So(string(out.WrittenBytes), ShouldEqual, "hello world")
+			So(test.Close(), ShouldBeNil)
+			So(out.CloseCalls, ShouldEqual, 1)
+		})
+
+		Convey("copies the written bytes", func() {
+			out := new(WriteCloser)
+			test := spipe.NewAsyncWriteCloser(out)
+			buf := []byte("abc")
+
+			_, _ = test.Write(buf)
+			buf[0] = 'z'
+
+			So(test.Close(), ShouldBeNil)
+			So(string(out.WrittenBytes), ShouldEqual, "abc")
+		})
+
+		Convey("drops when full", func() {
+			out := &blockingWC{release: make(chan struct{})}
+			test := spipe.NewAsyncWriteCloser(out).QueueSize(1).DropWhenFull(true)
+
+			// The first write is taken by the worker, the second fills the
+			// queue and the rest are dropped.
+			_, _ = test.Write([]byte("a"))
+			time.Sleep(10 * time.Millisecond)
+			_, _ = test.Write([]byte("b"))
+
+			n, err := test.Write([]byte("cd"))
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 2)
+			So(test.Dropped(), ShouldEqual, 2)
+
+			close(out.release)
+
+			So(test.Close(), ShouldBeNil)
+			So(string(out.WrittenBytes), ShouldEqual, "ab")
+		})
+
+		Convey("holds output errors", func() {
+			out := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+			test := spipe.NewAsyncWriteCloser(out)
+
+			_, err := test.Write([]byte("a"))
+			So(err, ShouldBeNil)
+			So(test.Flush(), ShouldResemble, errors.New("hiya!"))
+
+			_, err = test.Write([]byte("b"))
+			So(err, ShouldResemble, errors.New("hiya!"))
+
+			err = test.Close()
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "hiya!")
+			So(out.CloseCalls, ShouldEqual, 1)
+		})
+
+		Convey("after close", func() {
+			test := spipe.NewAsyncWriteCloser(new(WriteCloser))
+
+			So(test.Close(), ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+
+			_, err := test.Write([]byte("a"))
+			So(err, ShouldEqual, os.ErrClosed)
+		})
+
+		Convey("limited", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			out := new(WriteCloser)
+			test := spipe.NewAsyncWriteCloser(out).
+				Limit(spipe.NewLimiter(1, 1)).
+				Context(ctx)
+
+			_, err := test.Write([]byte("a"))
+			So(err, ShouldBeNil)
+			So(test.Flush(), ShouldEqual, context.Canceled)
+			So(len(out.WrittenBytes), ShouldEqual, 0)
+		})
+	})
+}
+
+func TestAsyncWriteCloser_SplitWriter(t *testing.T) {
+	Convey("AsyncWriteCloser as a limited secondary", t, func() {
+		primary := new(strings.Builder)
+		backup := new(WriteCloser)
+		async := spipe.NewAsyncWriteCloser(backup).
+			Limit(spipe.NewLimiter(1000, 10)).
+			QueueSize(16)
+
+		test := spipe.NewSplitWriter(primary, async)
+		start := time.Now()
+
+		for i := 0; i < 10; i++ {
+			_, err := test.Write([]byte("0123456789"))
+			So(err, ShouldBeNil)
+		}
+
+		So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+		So(async.Close(), ShouldBeNil)
+		So(string(backup.WrittenBytes), ShouldEqual, primary.String())
+	})
+}
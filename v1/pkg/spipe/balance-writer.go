@@ -0,0 +1,307 @@
+package spipe
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrNoOutputs is returned when a record cannot be written because every
+// output in a BalanceWriteCloser's pool has failed.
+var ErrNoOutputs = errors.New("spipe: no healthy outputs available")
+
+// BalanceMode selects how a BalanceWriteCloser picks the output for a record.
+type BalanceMode int
+
+const (
+	// RoundRobin sends each record to the next healthy output in turn.
+	RoundRobin BalanceMode = iota
+
+	// LeastLoaded sends each record to the healthy output with the fewest
+	// records queued or being written.
+	LeastLoaded
+)
+
+// DefaultQueueSize is the number of records each output of a
+// BalanceWriteCloser may have queued before writes block.
+const DefaultQueueSize = 64
+
+// BalanceWriteCloser defines an io.WriteCloser implementation that splits its
+// input into records and distributes those records across a pool of outputs.
+//
+// Unlike SplitWriteCloser, each record is written to exactly one output.  Each
+// output is written to by its own goroutine from a bounded queue.  An output
+// that returns an error from Write is taken out of the pool, and the record it
+// failed on, along with anything still queued for it, is handed to the
+// remaining outputs.
+//
+// Write must not be called concurrently.
+type BalanceWriteCloser interface {
+	io.WriteCloser
+
+	// Mode sets how outputs are picked for each record.  Defaults to
+	// RoundRobin.
+	Mode(BalanceMode) BalanceWriteCloser
+
+	// Delimiter sets the byte used to separate records.  Defaults to
+	// DefaultDelimiter.
+	Delimiter(byte) BalanceWriteCloser
+
+	// QueueSize sets the number of records each output may have queued before
+	// writes block.  Has no effect once the first record has been written.
+	// Defaults to DefaultQueueSize.
+	QueueSize(int) BalanceWriteCloser
+
+	// Flush writes out any held partial record as if it were complete and waits
+	// for every queued record to be written.
+	Flush() error
+
+	// Healthy returns the number of outputs still in the pool.
+	Healthy() int
+}
+
+// NewBalanceWriteCloser constructs a new BalanceWriteCloser instance that
+// distributes records across the given outputs.
+func NewBalanceWriteCloser(outputs ...io.WriteCloser) BalanceWriteCloser {
+	out := &balanceWriteCloser{
+		members:   make([]*balanceMember, len(outputs)),
+		framer:    recordFramer{delim: DefaultDelimiter},
+		queueSize: DefaultQueueSize,
+		healthy:   len(outputs),
+	}
+
+	for i, w := range outputs {
+		out.members[i] = &balanceMember{out: w}
+	}
+
+	return out
+}
+
+type balanceMember struct {
+	out     io.WriteCloser
+	queue   chan []byte
+	pending int64
+	dead    bool
+}
+
+type balanceWriteCloser struct {
+	members   []*balanceMember
+	framer    recordFramer
+	mode      BalanceMode
+	queueSize int
+	started   bool
+	closed    bool
+
+	// inflight tracks records that have been accepted but not yet written or
+	// dropped.
+	inflight sync.WaitGroup
+	workers  sync.WaitGroup
+
+	// mu guards the fields below.
+	mu      sync.Mutex
+	cursor  int
+	healthy int
+	errs    []error
+	dropped bool
+}
+
+// Write splits the given bytes into records and queues each complete record
+// for one of the healthy outputs.
+//
+// The returned byte count is the number of bytes consumed from p, which
+// includes bytes held back as part of an incomplete record.
+func (b *balanceWriteCloser) Write(p []byte) (int, error) {
+	if b.closed {
+		return 0, os.ErrClosed
+	}
+
+	b.start()
+	return b.framer.frame(p, b.accept)
+}
+
+func (b *balanceWriteCloser) Flush() error {
+	if b.closed {
+		return os.ErrClosed
+	}
+
+	b.start()
+	err := b.framer.flush(b.accept)
+	b.inflight.Wait()
+	return err
+}
+
+// Close writes out any held partial record, waits for the queued records to be
+// written and closes every output.  Later calls return nil, and writes after
+// Close return os.ErrClosed.
+func (b *balanceWriteCloser) Close() error {
+	if b.closed {
+		return nil
+	}
+
+	flushErr := b.Flush()
+	b.closed = true
+
+	for _, m := range b.members {
+		close(m.queue)
+	}
+
+	b.workers.Wait()
+
+	errs := NewMultiErrorBuilder()
+
+	if flushErr != ErrNoOutputs {
+		errs.Add(flushErr)
+	}
+
+	errs.Add(b.errs...)
+
+	for _, m := range b.members {
+		errs.Add(m.out.Close())
+	}
+
+	if b.dropped {
+		errs.Add(ErrNoOutputs)
+	}
+
+	return errs.Build()
+}
+
+func (b *balanceWriteCloser) Mode(m BalanceMode) BalanceWriteCloser {
+	b.mode = m
+	return b
+}
+
+func (b *balanceWriteCloser) Delimiter(d byte) BalanceWriteCloser {
+	b.framer.delim = d
+	return b
+}
+
+func (b *balanceWriteCloser) QueueSize(n int) BalanceWriteCloser {
+	if n < 1 {
+		n = 1
+	}
+
+	b.queueSize = n
+	return b
+}
+
+func (b *balanceWriteCloser) Healthy() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.healthy
+}
+
+func (b *balanceWriteCloser) start() {
+	if b.started {
+		return
+	}
+
+	b.started = true
+
+	for _, m := range b.members {
+		m.queue = make(chan []byte, b.queueSize)
+		b.workers.Add(1)
+		go b.work(m)
+	}
+}
+
+// accept takes ownership of a copy of the given record and queues it.
+func (b *balanceWriteCloser) accept(rec []byte) error {
+	b.inflight.Add(1)
+	return b.dispatch(append([]byte(nil), rec...))
+}
+
+// dispatch queues the given record for a healthy output, or drops it if there
+// are none left.
+func (b *balanceWriteCloser) dispatch(rec []byte) error {
+	m := b.pick()
+
+	if m == nil {
+		b.mu.Lock()
+		b.dropped = true
+		b.mu.Unlock()
+		b.inflight.Done()
+		return ErrNoOutputs
+	}
+
+	m.queue <- rec
+
+	return nil
+}
+
+// pick selects the healthy output the next record should be sent to and
+// increments its pending count.
+func (b *balanceWriteCloser) pick() (out *balanceMember) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ln := len(b.members)
+	var least int64
+
+	for i := 0; i < ln; i++ {
+		m := b.members[(b.cursor+i)%ln]
+
+		if m.dead {
+			continue
+		}
+
+		if b.mode == RoundRobin {
+			b.cursor = (b.cursor + i + 1) % ln
+			out = m
+			break
+		}
+
+		if p := atomic.LoadInt64(&m.pending); out == nil || p < least {
+			out, least = m, p
+		}
+	}
+
+	if out != nil {
+		if b.mode == LeastLoaded {
+			b.cursor = (b.cursor + 1) % ln
+		}
+
+		atomic.AddInt64(&out.pending, 1)
+	}
+
+	return
+}
+
+func (b *balanceWriteCloser) work(m *balanceMember) {
+	defer b.workers.Done()
+
+	for rec := range m.queue {
+		if !b.isDead(m) {
+			err := writeRecordTo(m.out, rec)
+
+			if err == nil {
+				atomic.AddInt64(&m.pending, -1)
+				b.inflight.Done()
+				continue
+			}
+
+			b.kill(m, err)
+		}
+
+		// Hand the record off to another output.
+		atomic.AddInt64(&m.pending, -1)
+		_ = b.dispatch(rec)
+	}
+}
+
+func (b *balanceWriteCloser) isDead(m *balanceMember) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return m.dead
+}
+
+func (b *balanceWriteCloser) kill(m *balanceMember, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m.dead = true
+	b.healthy--
+	b.errs = append(b.errs, err)
+}
@@ -0,0 +1,147 @@
+package spipe_test
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+type blockingWC struct {
+	WriteCloser
+	release chan struct{}
+}
+
+func (b *blockingWC) Write(p []byte) (int, error) {
+	<-b.release
+	return b.WriteCloser.Write(p)
+}
+
+func TestBalanceWriteCloser_Write(t *testing.T) {
+	Convey("BalanceWriteCloser.Write", t, func() {
+		input := "a\nb\nc\nd\ne\nf\n"
+
+		Convey("round robin", func() {
+			a, b, c := new(WriteCloser), new(WriteCloser), new(WriteCloser)
+
+			test := spipe.NewBalanceWriteCloser(a, b, c)
+			n, err := test.Write([]byte(input))
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(input))
+			So(test.Close(), ShouldBeNil)
+			So(string(a.WrittenBytes), ShouldEqual, "a\nd\n")
+			So(string(b.WrittenBytes), ShouldEqual, "b\ne\n")
+			So(string(c.WrittenBytes), ShouldEqual, "c\nf\n")
+			So(a.CloseCalls, ShouldEqual, 1)
+			So(b.CloseCalls, ShouldEqual, 1)
+			So(c.CloseCalls, ShouldEqual, 1)
+		})
+
+		Convey("least loaded", func() {
+			slow := &blockingWC{release: make(chan struct{})}
+			fast := new(WriteCloser)
+
+			test := spipe.NewBalanceWriteCloser(slow, fast).
+				Mode(spipe.LeastLoaded).
+				QueueSize(8)
+
+			// Give the fast output time to drain its queue between records.
+			for _, rec := range strings.SplitAfter(input, "\n") {
+				_, err := test.Write([]byte(rec))
+				So(err, ShouldBeNil)
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			close(slow.release)
+			So(test.Close(), ShouldBeNil)
+
+			So(string(slow.WrittenBytes), ShouldEqual, "a\n")
+			So(len(slow.WrittenBytes)+len(fast.WrittenBytes), ShouldEqual, len(input))
+		})
+
+		Convey("failing output is removed from the pool", func() {
+			a := new(WriteCloser)
+			b := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+
+			test := spipe.NewBalanceWriteCloser(a, b)
+
+			_, err := test.Write([]byte(input))
+			So(err, ShouldBeNil)
+			So(test.Flush(), ShouldBeNil)
+			So(test.Healthy(), ShouldEqual, 1)
+
+			errs, ok := test.Close().(spipe.MultiError)
+
+			So(ok, ShouldBeTrue)
+			So(errs.Errors(), ShouldResemble, []error{errors.New("hiya!")})
+			So(string(a.WrittenBytes), ShouldHaveLength, len(input))
+			So(b.CloseCalls, ShouldEqual, 1)
+		})
+
+		Convey("every output failing", func() {
+			a := &WriteCloser{WriteErrors: []error{errors.New("a")}}
+
+			test := spipe.NewBalanceWriteCloser(a)
+
+			_, err := test.Write([]byte("a\n"))
+			So(err, ShouldBeNil)
+			So(test.Flush(), ShouldBeNil)
+
+			_, err = test.Write([]byte("b\n"))
+			So(err, ShouldEqual, spipe.ErrNoOutputs)
+
+			errs, ok := test.Close().(spipe.MultiError)
+
+			So(ok, ShouldBeTrue)
+			So(errs.Errors(), ShouldResemble, []error{
+				errors.New("a"),
+				spipe.ErrNoOutputs,
+			})
+		})
+
+		Convey("short writes count as failures", func() {
+			a := &WriteCloser{WriteCounts: []int{0}}
+			b := new(WriteCloser)
+
+			test := spipe.NewBalanceWriteCloser(a, b)
+			_, _ = test.Write([]byte("a\n"))
+
+			errs, ok := test.Close().(spipe.MultiError)
+
+			So(ok, ShouldBeTrue)
+			So(errs.Errors(), ShouldResemble, []error{io.ErrShortWrite})
+			So(string(b.WrittenBytes), ShouldEqual, "a\n")
+		})
+	})
+}
+
+func TestBalanceWriteCloser_Close(t *testing.T) {
+	Convey("BalanceWriteCloser.Close", t, func() {
+		Convey("partial record", func() {
+			a := new(WriteCloser)
+
+			test := spipe.NewBalanceWriteCloser(a)
+			_, _ = test.Write([]byte("abc"))
+
+			So(test.Close(), ShouldBeNil)
+			So(string(a.WrittenBytes), ShouldEqual, "abc")
+		})
+
+		Convey("errors", func() {
+			fn := func() error { return errors.New("hi") }
+			a, b := &testWC{cl: fn}, &testWC{cl: fn}
+
+			errs, ok := spipe.NewBalanceWriteCloser(a, b).Close().(spipe.MultiError)
+
+			So(ok, ShouldBeTrue)
+			So(errs.Error(), ShouldEqual, "hi\nhi")
+		})
+	})
+}
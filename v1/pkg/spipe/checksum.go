@@ -0,0 +1,158 @@
+package spipe
+
+import (
+	"bytes"
+	"errors"
+	"hash"
+	"io"
+	"os"
+	"syscall"
+)
+
+// checksums computes digests over the bytes accepted by a split writer's
+// primary output, and verifies the writer's outputs against them.
+//
+// A nil checksums computes nothing.
+type checksums struct {
+	digests []hash.Hash
+
+	// newHash creates the hashes used for verification, nil if outputs are not
+	// being verified.
+	newHash func() hash.Hash
+	sum     hash.Hash
+
+	size int64
+}
+
+func (c *checksums) add(p []byte) {
+	if c == nil {
+		return
+	}
+
+	for _, h := range c.digests {
+		h.Write(p)
+	}
+
+	if c.sum != nil {
+		c.sum.Write(p)
+	}
+
+	c.size += int64(len(p))
+}
+
+func (c *checksums) addString(s string) {
+	if c == nil || len(s) == 0 {
+		return
+	}
+
+	for _, h := range c.digests {
+		io.WriteString(h, s)
+	}
+
+	if c.sum != nil {
+		io.WriteString(c.sum, s)
+	}
+
+	c.size += int64(len(s))
+}
+
+// verify reads back the given output and compares it with the stream, returning
+// nil if the output matches or cannot be read back.  A file whose first read is
+// refused because it was opened write-only is treated as one that cannot be
+// read back, every other read failure is returned.
+func (c *checksums) verify(out io.Writer) error {
+	if c == nil || c.newHash == nil {
+		return nil
+	}
+
+	src, err := c.readBack(out)
+	if src == nil || err != nil {
+		return err
+	}
+
+	h := c.newHash()
+	buf := make([]byte, 32*1024)
+
+	m, err := src.Read(buf)
+	if err != nil && err != io.EOF {
+		if writeOnly(out, err) {
+			return nil
+		}
+
+		return err
+	}
+
+	h.Write(buf[:m])
+	n := int64(m)
+
+	if err == nil {
+		rest, err := io.CopyBuffer(h, src, buf)
+		if err != nil {
+			return err
+		}
+
+		n += rest
+	}
+
+	if n != c.size {
+		return ErrSizeMismatch
+	}
+
+	if !bytes.Equal(h.Sum(nil), c.sum.Sum(nil)) {
+		return ErrChecksumMismatch
+	}
+
+	return nil
+}
+
+// readBack returns a reader over the last size bytes of the given output, or
+// nil if the output cannot be read back.
+//
+// Outputs that are io.Seekers are read back from the end, so streams appended
+// to existing files are verified correctly.  Outputs that cannot be seeked,
+// such as pipes, are skipped.
+func (c *checksums) readBack(out io.Writer) (io.Reader, error) {
+	ra, isReaderAt := out.(io.ReaderAt)
+	rs, isReadSeeker := out.(io.ReadSeeker)
+
+	if !isReaderAt && !isReadSeeker {
+		return nil, nil
+	}
+
+	start := int64(0)
+
+	if s, ok := out.(io.Seeker); ok {
+		end, err := s.Seek(0, io.SeekEnd)
+		if err != nil {
+			return nil, nil
+		}
+
+		if start = end - c.size; start < 0 {
+			return nil, ErrSizeMismatch
+		}
+	}
+
+	if isReaderAt {
+		return io.NewSectionReader(ra, start, c.size), nil
+	}
+
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return io.LimitReader(rs, c.size), nil
+}
+
+// writeOnly returns whether the given read error shows that the output is a
+// file opened write-only.
+func writeOnly(out io.Writer, err error) bool {
+	_, isFile := out.(*os.File)
+	return isFile && (errors.Is(err, syscall.EBADF) || errors.Is(err, os.ErrPermission))
+}
+
+// primaryWrote returns whether the primary output accepted a write that
+// returned the given error.
+func primaryWrote(err error) bool {
+	se := new(StreamError)
+	return err == nil || (errors.As(err, &se) && se.Index != 0)
+}
@@ -0,0 +1,195 @@
+package spipe_test
+
+import (
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func tempFile(prefix string) *os.File {
+	f, err := ioutil.TempFile("", prefix)
+	So(err, ShouldBeNil)
+	Reset(func() { os.Remove(f.Name()) })
+	return f
+}
+
+func TestSplitWriteCloser_Digest(t *testing.T) {
+	Convey("SplitWriteCloser.Digest", t, func() {
+		sha := sha256.New()
+		crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+
+		test := spipe.NewSplitWriteCloser(new(WriteCloser), new(WriteCloser)).
+			Digest(sha, crc)
+
+		_, _ = test.Write([]byte("hello"))
+		_, _ = test.WriteString(" world")
+		_ = test.WriteByte('!')
+
+		So(test.Close(), ShouldBeNil)
+
+		want := sha256.Sum256([]byte("hello world!"))
+		So(sha.Sum(nil), ShouldResemble, want[:])
+		So(crc.(interface{ Sum32() uint32 }).Sum32(), ShouldEqual,
+			crc32.Checksum([]byte("hello world!"), crc32.MakeTable(crc32.Castagnoli)))
+
+		Convey("only counts bytes the primary accepted", func() {
+			sha := sha256.New()
+			bad := &WriteCloser{
+				WriteErrors: []error{errors.New("hiya!")},
+				WriteCounts: []int{2},
+			}
+
+			test := spipe.NewSplitWriteCloser(bad).Digest(sha)
+			_, err := test.Write([]byte("lost"))
+
+			So(err, ShouldNotBeNil)
+
+			want := sha256.Sum256([]byte("lo"))
+			So(sha.Sum(nil), ShouldResemble, want[:])
+		})
+
+		Convey("counts every byte the primary accepted on a short secondary", func() {
+			sha := sha256.New()
+			short := &WriteCloser{WriteCounts: []int{2}}
+
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), short).Digest(sha)
+			n, err := test.Write([]byte("kept"))
+
+			So(errors.Is(err, io.ErrShortWrite), ShouldBeTrue)
+			So(n, ShouldEqual, 4)
+
+			want := sha256.Sum256([]byte("kept"))
+			So(sha.Sum(nil), ShouldResemble, want[:])
+		})
+	})
+}
+
+func TestSplitWriteCloser_Verify(t *testing.T) {
+	Convey("SplitWriteCloser.Verify", t, func() {
+		a, b := tempFile("spipe-verify-a"), tempFile("spipe-verify-b")
+
+		Convey("matching outputs", func() {
+			test := spipe.NewSplitWriteCloser(a, b).Verify(sha256.New)
+
+			_, _ = test.Write([]byte("hello "))
+			_, _ = test.WriteString("world")
+
+			So(test.Close(), ShouldBeNil)
+
+			// The outputs are closed after verification.
+			So(a.Close(), ShouldNotBeNil)
+		})
+
+		Convey("tampered output", func() {
+			test := spipe.NewSplitWriteCloser(a, b).Verify(sha256.New)
+
+			_, _ = test.Write([]byte("hello world"))
+			_, _ = b.WriteAt([]byte("J"), 0)
+
+			err := test.Close()
+			se := new(spipe.StreamError)
+
+			So(errors.As(err, &se), ShouldBeTrue)
+			So(se.Op, ShouldEqual, spipe.OpVerify)
+			So(se.Index, ShouldEqual, 1)
+			So(errors.Is(err, spipe.ErrChecksumMismatch), ShouldBeTrue)
+
+			Convey("ignored", func() {
+				c, d := tempFile("spipe-verify-c"), tempFile("spipe-verify-d")
+				test := spipe.NewSplitWriteCloser(c, d).
+					IgnoreErrors(true).
+					Verify(sha256.New)
+
+				_, _ = test.Write([]byte("hello world"))
+				_, _ = d.WriteAt([]byte("J"), 0)
+
+				So(test.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("truncated output", func() {
+			test := spipe.NewSplitWriteCloser(a, b).Verify(sha256.New)
+
+			_, _ = test.Write([]byte("hello world"))
+			So(b.Truncate(5), ShouldBeNil)
+
+			err := test.Close()
+
+			So(errors.Is(err, spipe.ErrSizeMismatch), ShouldBeTrue)
+		})
+
+		Convey("appended output", func() {
+			_, _ = a.WriteString("existing data\n")
+
+			newCRC := func() hash.Hash { return crc32.NewIEEE() }
+			test := spipe.NewSplitWriteCloser(a).Verify(newCRC)
+			_, _ = test.Write([]byte("hello world"))
+
+			So(test.Close(), ShouldBeNil)
+		})
+
+		Convey("unreadable outputs are skipped", func() {
+			out := new(WriteCloser)
+			test := spipe.NewSplitWriteCloser(out, a).Verify(sha256.New)
+
+			_, _ = test.Write([]byte("hello world"))
+
+			So(test.Close(), ShouldBeNil)
+			So(out.CloseCalls, ShouldEqual, 1)
+		})
+
+		Convey("write-only files are skipped", func() {
+			dir, err := ioutil.TempDir("", "spipe-verify")
+			So(err, ShouldBeNil)
+			Reset(func() { os.RemoveAll(dir) })
+
+			name := filepath.Join(dir, "out")
+			out, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0600)
+			So(err, ShouldBeNil)
+
+			test := spipe.NewSplitWriteCloser(a, out).Verify(sha256.New)
+			_, _ = test.Write([]byte("hello world"))
+
+			So(test.Close(), ShouldBeNil)
+
+			written, err := ioutil.ReadFile(name)
+			So(err, ShouldBeNil)
+			So(string(written), ShouldEqual, "hello world")
+		})
+
+		Convey("read back failures are reported", func() {
+			out := &failingReaderAt{err: errors.New("input/output error")}
+			test := spipe.NewSplitWriteCloser(a, out).Verify(sha256.New)
+			_, _ = test.Write([]byte("hello world"))
+
+			err := test.Close()
+			So(errors.Is(err, out.err), ShouldBeTrue)
+
+			se := new(spipe.StreamError)
+			So(errors.As(err, &se), ShouldBeTrue)
+			So(se.Op, ShouldEqual, spipe.OpVerify)
+			So(se.Index, ShouldEqual, 1)
+		})
+	})
+}
+
+// failingReaderAt is an output whose reads all fail with err.
+type failingReaderAt struct {
+	WriteCloser
+	err error
+}
+
+func (f *failingReaderAt) ReadAt([]byte, int64) (int, error) {
+	return 0, f.err
+}
@@ -0,0 +1,103 @@
+package spipe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+var (
+	// ErrChecksumMismatch is the cause of a ChunkError returned when a part's
+	// checksum does not match its manifest entry, and of a StreamError returned
+	// when a verified split writer output does not match the written stream.
+	ErrChecksumMismatch = errors.New("spipe: checksum mismatch")
+
+	// ErrSizeMismatch is the cause of a ChunkError returned when a part's size
+	// does not match its manifest entry, and of a StreamError returned when a
+	// verified split writer output is shorter or longer than the written
+	// stream.
+	ErrSizeMismatch = errors.New("spipe: size mismatch")
+)
+
+// ChunkError is returned by a chunk reader when a part fails verification.
+type ChunkError struct {
+	// Index is the zero based index of the failing part.
+	Index int
+
+	// Err is the reason the part failed verification.
+	Err error
+}
+
+func (c *ChunkError) Error() string {
+	return fmt.Sprintf("spipe: chunk %d: %s", c.Index, c.Err)
+}
+
+// Unwrap returns the reason the part failed verification.
+func (c *ChunkError) Unwrap() error {
+	return c.Err
+}
+
+// ChunkOpener opens the part with the given zero based index for reading.
+type ChunkOpener func(index int) (io.ReadCloser, error)
+
+// NewChunkReadCloser returns a new MultiReadCloser instance that reads back
+// the parts described by the given Manifest as a single stream.
+//
+// Each part is opened lazily when it is reached, and closed as soon as it has
+// been consumed.  As each part is consumed, its size and checksum are compared
+// against the manifest, and a *ChunkError identifying the part is returned in
+// place of io.EOF if they do not match.
+func NewChunkReadCloser(m Manifest, open ChunkOpener) MultiReadCloser {
+	inputs := make([]io.ReadCloser, len(m.Parts))
+
+	for i := range m.Parts {
+		index := i
+
+		inputs[i] = &chunkVerifier{
+			ReadCloser: NewLazyReadCloser(func() (io.ReadCloser, error) {
+				return open(index)
+			}),
+			index: index,
+			part:  m.Parts[i],
+			hash:  sha256.New(),
+		}
+	}
+
+	return NewMultiReadCloser(inputs...).CloseImmediately(true)
+}
+
+// chunkVerifier checks the bytes read from a part against its manifest entry.
+type chunkVerifier struct {
+	io.ReadCloser
+	index int
+	part  ManifestPart
+	hash  hash.Hash
+	read  int64
+}
+
+func (c *chunkVerifier) Read(p []byte) (n int, err error) {
+	n, err = c.ReadCloser.Read(p)
+	c.hash.Write(p[:n])
+	c.read += int64(n)
+
+	if c.read > c.part.Size {
+		return n, &ChunkError{c.index, ErrSizeMismatch}
+	}
+
+	if err != io.EOF {
+		return
+	}
+
+	if c.read != c.part.Size {
+		return n, &ChunkError{c.index, ErrSizeMismatch}
+	}
+
+	if hex.EncodeToString(c.hash.Sum(nil)) != c.part.Checksum {
+		return n, &ChunkError{c.index, ErrChecksumMismatch}
+	}
+
+	return
+}
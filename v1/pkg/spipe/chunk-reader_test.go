@@ -0,0 +1,86 @@
+package spipe_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestChunkReadCloser_Read(t *testing.T) {
+	Convey("ChunkReadCloser.Read", t, func() {
+		parts := new(memParts)
+		w := spipe.NewChunkWriteCloser(4, parts.create, nil)
+
+		_, _ = w.Write([]byte("hello world"))
+		So(w.Close(), ShouldBeNil)
+
+		m := w.Manifest()
+
+		Convey("read back", func() {
+			opened := 0
+			open := func(i int) (io.ReadCloser, error) {
+				opened++
+				return ioutil.NopCloser(strings.NewReader(parts.strings()[i])), nil
+			}
+
+			r := spipe.NewChunkReadCloser(m, open)
+			So(opened, ShouldEqual, 0)
+
+			out, err := ioutil.ReadAll(r)
+
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "hello world")
+			So(opened, ShouldEqual, 3)
+		})
+
+		Convey("corrupt part", func() {
+			open := func(i int) (io.ReadCloser, error) {
+				if i == 1 {
+					return ioutil.NopCloser(strings.NewReader("oops")), nil
+				}
+				return ioutil.NopCloser(strings.NewReader(parts.strings()[i])), nil
+			}
+
+			_, err := ioutil.ReadAll(spipe.NewChunkReadCloser(m, open))
+
+			var chunkErr *spipe.ChunkError
+			So(errors.As(err, &chunkErr), ShouldBeTrue)
+			So(chunkErr.Index, ShouldEqual, 1)
+			So(chunkErr.Err, ShouldEqual, spipe.ErrChecksumMismatch)
+			So(err.Error(), ShouldEqual, "Read input 1: spipe: chunk 1: spipe: checksum mismatch")
+		})
+
+		Convey("truncated part", func() {
+			open := func(i int) (io.ReadCloser, error) {
+				if i == 2 {
+					return ioutil.NopCloser(strings.NewReader("rl")), nil
+				}
+				return ioutil.NopCloser(strings.NewReader(parts.strings()[i])), nil
+			}
+
+			_, err := ioutil.ReadAll(spipe.NewChunkReadCloser(m, open))
+
+			var chunkErr *spipe.ChunkError
+			So(errors.As(err, &chunkErr), ShouldBeTrue)
+			So(chunkErr, ShouldResemble, &spipe.ChunkError{Index: 2, Err: spipe.ErrSizeMismatch})
+		})
+
+		Convey("oversized part", func() {
+			open := func(i int) (io.ReadCloser, error) {
+				return ioutil.NopCloser(strings.NewReader("too long")), nil
+			}
+
+			_, err := ioutil.ReadAll(spipe.NewChunkReadCloser(m, open))
+
+			var chunkErr *spipe.ChunkError
+			So(errors.As(err, &chunkErr), ShouldBeTrue)
+			So(chunkErr, ShouldResemble, &spipe.ChunkError{Index: 0, Err: spipe.ErrSizeMismatch})
+		})
+	})
+}
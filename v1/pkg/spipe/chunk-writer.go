@@ -0,0 +1,143 @@
+package spipe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"hash"
+	"io"
+)
+
+// Manifest describes a stream that was cut into fixed size parts by a
+// ChunkWriteCloser.
+type Manifest struct {
+	// ChunkSize is the maximum size of each part.
+	ChunkSize int64 `json:"chunkSize"`
+
+	// Size is the total size of the stream.
+	Size int64 `json:"size"`
+
+	// Parts lists each part of the stream in order.
+	Parts []ManifestPart `json:"parts"`
+}
+
+// ManifestPart describes a single part of a chunked stream.
+type ManifestPart struct {
+	// Size is the size of the part in bytes.
+	Size int64 `json:"size"`
+
+	// Checksum is the hex encoded SHA-256 digest of the part.
+	Checksum string `json:"checksum"`
+}
+
+// ReadManifest decodes a JSON encoded Manifest from the given reader.
+func ReadManifest(r io.Reader) (m Manifest, err error) {
+	err = json.NewDecoder(r).Decode(&m)
+	return
+}
+
+// ChunkWriteCloser defines an io.WriteCloser implementation that cuts its
+// input into fixed size parts, recording the size and checksum of each part in
+// a Manifest.
+type ChunkWriteCloser interface {
+	io.WriteCloser
+
+	// Manifest returns the manifest for the parts that have been completed.
+	Manifest() Manifest
+}
+
+// NewChunkWriteCloser constructs a new ChunkWriteCloser instance that writes
+// parts of the given size to outputs created by the given PartFactory.
+//
+// When closed, the JSON encoded manifest will be written to the given manifest
+// writer, if it is not nil.
+func NewChunkWriteCloser(size int64, parts PartFactory, manifest io.Writer) ChunkWriteCloser {
+	out := &chunkWriteCloser{
+		parts:    parts,
+		manifest: manifest,
+		hash:     sha256.New(),
+	}
+
+	out.result.ChunkSize = size
+	out.rotate = NewRotateWriteCloser(out.createPart).
+		MaxBytes(size).
+		OnRotate(out.finishPart)
+
+	return out
+}
+
+type chunkWriteCloser struct {
+	parts    PartFactory
+	manifest io.Writer
+	rotate   RotateWriteCloser
+	result   Manifest
+	closed   bool
+
+	// hash and size track the part currently being written.
+	hash hash.Hash
+	size int64
+}
+
+func (c *chunkWriteCloser) Write(p []byte) (int, error) {
+	return c.rotate.Write(p)
+}
+
+// Close closes the last part and writes out the manifest.  Later calls return
+// nil.
+func (c *chunkWriteCloser) Close() error {
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+
+	if err := c.rotate.Close(); err != nil {
+		return err
+	}
+
+	if c.manifest == nil {
+		return nil
+	}
+
+	return json.NewEncoder(c.manifest).Encode(c.result)
+}
+
+func (c *chunkWriteCloser) Manifest() Manifest {
+	return c.result
+}
+
+func (c *chunkWriteCloser) createPart(index int) (io.WriteCloser, error) {
+	w, err := c.parts(index)
+	if err != nil {
+		return nil, err
+	}
+
+	c.hash.Reset()
+	c.size = 0
+
+	return &chunkPart{WriteCloser: w, chunk: c}, nil
+}
+
+func (c *chunkWriteCloser) finishPart(int) error {
+	c.result.Size += c.size
+	c.result.Parts = append(c.result.Parts, ManifestPart{
+		Size:     c.size,
+		Checksum: hex.EncodeToString(c.hash.Sum(nil)),
+	})
+
+	return nil
+}
+
+// chunkPart feeds the bytes written to a part into its chunk writer's running
+// checksum.
+type chunkPart struct {
+	io.WriteCloser
+	chunk *chunkWriteCloser
+}
+
+func (c *chunkPart) Write(p []byte) (n int, err error) {
+	n, err = c.WriteCloser.Write(p)
+	c.chunk.hash.Write(p[:n])
+	c.chunk.size += int64(n)
+	return
+}
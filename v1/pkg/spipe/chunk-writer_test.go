@@ -0,0 +1,45 @@
+package spipe_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestChunkWriteCloser(t *testing.T) {
+	Convey("ChunkWriteCloser", t, func() {
+		parts := new(memParts)
+		manifest := new(bytes.Buffer)
+
+		test := spipe.NewChunkWriteCloser(4, parts.create, manifest)
+
+		n, err := test.Write([]byte("hello world"))
+
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 11)
+		So(test.Close(), ShouldBeNil)
+		So(parts.strings(), ShouldResemble, []string{"hell", "o wo", "rld"})
+
+		m, err := spipe.ReadManifest(manifest)
+
+		So(err, ShouldBeNil)
+		So(m, ShouldResemble, test.Manifest())
+		So(m.ChunkSize, ShouldEqual, 4)
+		So(m.Size, ShouldEqual, 11)
+		So(m.Parts, ShouldResemble, []spipe.ManifestPart{
+			{4, sha("hell")},
+			{4, sha("o wo")},
+			{3, sha("rld")},
+		})
+	})
+}
+
+func sha(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,293 @@
+package spipe_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipetest"
+)
+
+// concat returns the bytes written to the given parts, in order.
+func (m memParts) concat() []byte {
+	return []byte(strings.Join(m.strings(), ""))
+}
+
+func TestConformance_Readers(t *testing.T) {
+	content := []byte(strings.Repeat("split-pipe\n", 100))
+	a, b := content[:300], content[300:]
+
+	Convey("Reader conformance", t, func() {
+		Convey("MultiReader", func() {
+			So(spipetest.CheckReader(func() io.Reader {
+				return spipe.NewMultiReader(bytes.NewReader(a), strings.NewReader(""), bytes.NewReader(b))
+			}, content), ShouldBeNil)
+		})
+
+		multi := func() []io.ReadCloser {
+			return []io.ReadCloser{
+				ioutil.NopCloser(bytes.NewReader(a)),
+				ioutil.NopCloser(strings.NewReader("")),
+				ioutil.NopCloser(bytes.NewReader(b)),
+			}
+		}
+
+		Convey("MultiReadCloser", func() {
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewMultiReadCloser(multi()...)
+			}, content), ShouldBeNil)
+		})
+
+		Convey("MultiReadCloser closing immediately", func() {
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewMultiReadCloser(multi()...).CloseImmediately(true)
+			}, content), ShouldBeNil)
+		})
+
+		Convey("MultiReadCloser decompressing", func() {
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewMultiReadCloser(
+					ioutil.NopCloser(bytes.NewReader(gzipped(string(a)))),
+					ioutil.NopCloser(bytes.NewReader(b)),
+				).Decompress()
+			}, content), ShouldBeNil)
+		})
+
+		Convey("LazyReadCloser", func() {
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewLazyReadCloser(func() (io.ReadCloser, error) {
+					return ioutil.NopCloser(bytes.NewReader(content)), nil
+				})
+			}, content), ShouldBeNil)
+		})
+
+		Convey("ChunkReadCloser", func() {
+			parts := new(memParts)
+			w := spipe.NewChunkWriteCloser(256, parts.create, nil)
+			_, _ = w.Write(content)
+			So(w.Close(), ShouldBeNil)
+
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewChunkReadCloser(w.Manifest(), func(i int) (io.ReadCloser, error) {
+					return ioutil.NopCloser(strings.NewReader(parts.strings()[i])), nil
+				})
+			}, content), ShouldBeNil)
+		})
+
+		Convey("DecryptReader", func() {
+			key := testKey(1)
+			stream := encrypted(key, 100, string(content))
+
+			So(spipetest.CheckReader(func() io.Reader {
+				return spipe.NewDecryptReader(bytes.NewReader(stream), key, spipe.AESGCM)
+			}, content), ShouldBeNil)
+		})
+
+		Convey("Demux stream", func() {
+			muxed := new(bytes.Buffer)
+			mux := spipe.NewMux(muxed, 2).FrameSize(100)
+			_, _ = mux.Stream(1).Write(content)
+			So(mux.Close(), ShouldBeNil)
+
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewDemux(bytes.NewReader(muxed.Bytes()), 2).Stream(1)
+			}, content), ShouldBeNil)
+		})
+	})
+
+	Convey("Reader wrapper conformance", t, func() {
+		Convey("MultiReader", func() {
+			So(spipetest.CheckReaderWrapper(func(in io.ReadCloser) io.Reader {
+				return spipe.NewMultiReader(in)
+			}), ShouldBeNil)
+		})
+
+		Convey("MultiReadCloser", func() {
+			So(spipetest.CheckReaderWrapper(func(in io.ReadCloser) io.Reader {
+				return spipe.NewMultiReadCloser(in)
+			}), ShouldBeNil)
+		})
+
+		Convey("MultiReadCloser closing immediately", func() {
+			So(spipetest.CheckReaderWrapper(func(in io.ReadCloser) io.Reader {
+				return spipe.NewMultiReadCloser(in).CloseImmediately(true)
+			}), ShouldBeNil)
+		})
+
+		Convey("LazyReadCloser", func() {
+			So(spipetest.CheckReaderWrapper(func(in io.ReadCloser) io.Reader {
+				return spipe.NewLazyReadCloser(func() (io.ReadCloser, error) { return in, nil })
+			}), ShouldBeNil)
+		})
+	})
+}
+
+func TestConformance_Writers(t *testing.T) {
+	Convey("Writer conformance", t, func() {
+		Convey("SplitWriter", func() {
+			So(spipetest.CheckWriter(func() (io.Writer, func() []byte) {
+				primary, secondary := new(bytes.Buffer), new(bytes.Buffer)
+				return spipe.NewSplitWriter(primary, secondary), primary.Bytes
+			}), ShouldBeNil)
+		})
+
+		Convey("RouteWriter", func() {
+			So(spipetest.CheckWriter(func() (io.Writer, func() []byte) {
+				out := new(bytes.Buffer)
+				test := spipe.NewRouteWriter(out)
+				return test, func() []byte { _ = test.Flush(); return out.Bytes() }
+			}), ShouldBeNil)
+		})
+
+		Convey("ShardWriter", func() {
+			So(spipetest.CheckWriter(func() (io.Writer, func() []byte) {
+				out := new(bytes.Buffer)
+				test := spipe.NewShardWriter(func([]byte) ([]byte, error) { return nil, nil }, out)
+				return test, func() []byte { _ = test.Flush(); return out.Bytes() }
+			}), ShouldBeNil)
+		})
+
+		Convey("SplitWriteCloser", func() {
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				primary := new(spipetest.Writer)
+				return spipe.NewSplitWriteCloser(primary, new(spipetest.Writer)), primary.Bytes
+			}), ShouldBeNil)
+		})
+
+		Convey("SplitWriteCloser with transforms", func() {
+			gz, err := spipe.GzipTransform(1)
+			So(err, ShouldBeNil)
+
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				archive := new(spipetest.Writer)
+				test := spipe.NewSplitWriteCloser(new(spipetest.Writer), archive).Transform(1, gz)
+
+				return test, func() []byte {
+					out, _ := ioutil.ReadAll(spipe.NewMultiReadCloser(ioutil.NopCloser(bytes.NewReader(archive.Bytes()))).Decompress())
+					return out
+				}
+			}), ShouldBeNil)
+		})
+
+		Convey("AsyncWriteCloser", func() {
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				out := new(spipetest.Writer)
+				return spipe.NewAsyncWriteCloser(out), out.Bytes
+			}), ShouldBeNil)
+		})
+
+		Convey("BalanceWriteCloser", func() {
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				out := new(spipetest.Writer)
+				return spipe.NewBalanceWriteCloser(out), out.Bytes
+			}), ShouldBeNil)
+		})
+
+		Convey("RotateWriteCloser", func() {
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				parts := new(memParts)
+				return spipe.NewRotateWriteCloser(parts.create).MaxBytes(1000), func() []byte { return parts.concat() }
+			}), ShouldBeNil)
+		})
+
+		Convey("ChunkWriteCloser", func() {
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				parts := new(memParts)
+				return spipe.NewChunkWriteCloser(1000, parts.create, new(bytes.Buffer)), func() []byte { return parts.concat() }
+			}), ShouldBeNil)
+		})
+
+		Convey("EncryptWriter", func() {
+			key := testKey(1)
+
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				out := new(bytes.Buffer)
+				test, _ := spipe.NewEncryptWriter(out, key, spipe.AESGCM)
+
+				return test.ChunkSize(100), func() []byte {
+					plain, _ := ioutil.ReadAll(spipe.NewDecryptReader(out, key, spipe.AESGCM))
+					return plain
+				}
+			}), ShouldBeNil)
+		})
+
+		Convey("Mux stream", func() {
+			So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+				out := new(bytes.Buffer)
+				mux := spipe.NewMux(out, 2).FrameSize(100)
+
+				return mux.Stream(1), func() []byte {
+					_ = mux.Close()
+					plain, _ := ioutil.ReadAll(spipe.NewDemux(out, 2).Stream(1))
+					return plain
+				}
+			}), ShouldBeNil)
+		})
+
+		if _, err := exec.LookPath("sh"); err == nil {
+			Convey("CommandWriteCloser", func() {
+				So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+					out := new(bytes.Buffer)
+					cmd := exec.Command("sh", "-c", "cat")
+					cmd.Stdout = out
+
+					test, _ := spipe.NewCommandWriteCloser(cmd)
+					return test, out.Bytes
+				}), ShouldBeNil)
+			})
+		}
+	})
+
+	Convey("Writer wrapper conformance", t, func() {
+		Convey("SplitWriteCloser primary", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				return spipe.NewSplitWriteCloser(out, new(spipetest.Writer))
+			}), ShouldBeNil)
+		})
+
+		Convey("SplitWriteCloser secondary", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				return spipe.NewSplitWriteCloser(new(spipetest.Writer), out)
+			}), ShouldBeNil)
+		})
+
+		Convey("SplitWriteCloser with transforms", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				return spipe.NewSplitWriteCloser(new(spipetest.Writer), out).Transform(1, spipe.LineMap(func(line []byte) []byte {
+					return line
+				}))
+			}), ShouldBeNil)
+		})
+
+		Convey("AsyncWriteCloser", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				return spipe.NewAsyncWriteCloser(out)
+			}), ShouldBeNil)
+		})
+
+		Convey("BalanceWriteCloser", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				return spipe.NewBalanceWriteCloser(out)
+			}), ShouldBeNil)
+		})
+
+		Convey("RotateWriteCloser", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				return spipe.NewRotateWriteCloser(func(int) (io.WriteCloser, error) { return out, nil })
+			}), ShouldBeNil)
+		})
+
+		Convey("EncryptWriter", func() {
+			So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+				test, _ := spipe.NewEncryptWriter(out, testKey(1), spipe.AESGCM)
+				return test.ChunkSize(16)
+			}), ShouldBeNil)
+		})
+	})
+}
@@ -0,0 +1,244 @@
+package spipe
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+)
+
+// Decompressor wraps a compressed stream in a reader that decompresses it.
+type Decompressor func(io.Reader) (io.ReadCloser, error)
+
+// Format describes a compression format that can be recognised from the first
+// bytes of a stream.
+type Format struct {
+	// Name identifies the format.
+	Name string
+
+	// HeaderSize is the number of bytes Sniff needs to see.
+	HeaderSize int
+
+	// Magic is a fixed prefix every stream in this format starts with, if the
+	// format has one.  It lets the format be ruled out before HeaderSize bytes
+	// have arrived, so short streams such as interactive input are not held
+	// up while more bytes are awaited.
+	Magic []byte
+
+	// Sniff reports whether the given header is the start of a stream in this
+	// format.  The header is shorter than HeaderSize only if the stream is.
+	Sniff func(header []byte) bool
+
+	// Open wraps a stream in this format in a decompressing reader.
+	Open Decompressor
+}
+
+// MagicFormat returns a Format that is recognised by the given magic prefix.
+//
+// This can be used to register formats from outside the standard library, for
+// example zstd, whose streams start with 28 B5 2F FD.
+func MagicFormat(name string, magic []byte, open Decompressor) Format {
+	return Format{
+		Name:       name,
+		HeaderSize: len(magic),
+		Magic:      magic,
+		Sniff: func(header []byte) bool {
+			return bytes.HasPrefix(header, magic)
+		},
+		Open: open,
+	}
+}
+
+var (
+	// GzipFormat recognises gzip streams, including streams of several
+	// concatenated gzip members.
+	GzipFormat = MagicFormat("gzip", []byte{0x1f, 0x8b, 8}, func(r io.Reader) (io.ReadCloser, error) {
+		return gzip.NewReader(r)
+	})
+
+	// ZlibFormat recognises zlib streams by their two byte header.  The header
+	// is only a checksum over two bytes, so plain text that happens to start
+	// with a valid header, such as "x^" or "HK", will be mistaken for zlib.  It
+	// is therefore not one of the DefaultFormats, and should only be given to
+	// a Decompress option for inputs known to be compressed.
+	ZlibFormat = Format{
+		Name:       "zlib",
+		HeaderSize: 2,
+		Sniff: func(h []byte) bool {
+			return len(h) == 2 &&
+				h[0]&0x0f == 8 && // deflate compression
+				h[0]>>4 <= 7 && // window size of at most 32K
+				h[1]&0x20 == 0 && // no preset dictionary
+				(uint16(h[0])<<8|uint16(h[1]))%31 == 0
+		},
+		Open: zlib.NewReader,
+	}
+
+	// Bzip2Format recognises bzip2 streams by their stream header followed by
+	// a block or end of stream marker.
+	Bzip2Format = Format{
+		Name:       "bzip2",
+		HeaderSize: 10,
+		Magic:      []byte("BZh"),
+		Sniff: func(h []byte) bool {
+			return len(h) == 10 &&
+				bytes.HasPrefix(h, []byte("BZh")) &&
+				h[3] >= '1' && h[3] <= '9' &&
+				(bytes.Equal(h[4:], []byte{0x31, 0x41, 0x59, 0x26, 0x53, 0x59}) ||
+					bytes.Equal(h[4:], []byte{0x17, 0x72, 0x45, 0x38, 0x50, 0x90}))
+		},
+		Open: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(bzip2.NewReader(r)), nil
+		},
+	}
+)
+
+// DefaultFormats returns the formats that are recognised when no formats are
+// given to a Decompress option: gzip and bzip2.
+//
+// ZlibFormat is left out as its header is too weak to tell zlib from plain
+// text.  Raw DEFLATE streams have no header to recognise them by at all.
+// Inputs known to be raw DEFLATE can be wrapped with flate.NewReader by an
+// Opener instead.
+func DefaultFormats() []Format {
+	return []Format{GzipFormat, Bzip2Format}
+}
+
+// newDecompressReadCloser wraps the given input in a reader that recognises its
+// format on the first read and decompresses it.  Inputs in none of the given
+// formats are passed through unchanged.
+func newDecompressReadCloser(in io.ReadCloser, formats []Format) io.ReadCloser {
+	if len(formats) == 0 {
+		formats = DefaultFormats()
+	}
+
+	return &decompressReadCloser{in: in, formats: formats}
+}
+
+type decompressReadCloser struct {
+	in      io.ReadCloser
+	formats []Format
+
+	// stream is the reader data is read from once the format is known, nil
+	// before the first read.
+	stream io.Reader
+
+	// dec is the decompressor, nil if the input is not compressed.
+	dec io.ReadCloser
+
+	// buf holds the bytes read from the input while sniffing.
+	buf *bufio.Reader
+}
+
+func (d *decompressReadCloser) Read(p []byte) (int, error) {
+	if d.stream == nil {
+		if err := d.sniff(); err != nil {
+			return 0, err
+		}
+	}
+
+	return d.stream.Read(p)
+}
+
+// Close closes the decompressor, if any, and then the input.
+func (d *decompressReadCloser) Close() error {
+	errs := NewMultiErrorBuilder()
+
+	if d.dec != nil {
+		errs.Add(d.dec.Close())
+	}
+
+	errs.Add(d.in.Close())
+
+	return errs.Build()
+}
+
+// sniff recognises the input's format from its first bytes.
+//
+// Sniffing starts with the bytes returned by the first read of the input, and
+// only reads more while a format, in order of preference, could still match
+// but needs more bytes to tell.  Formats are ruled out early by their Magic
+// prefix, so short streams are not held up waiting for a full header.
+func (d *decompressReadCloser) sniff() error {
+	size := 0
+	for _, f := range d.formats {
+		if f.HeaderSize > size {
+			size = f.HeaderSize
+		}
+	}
+
+	// Kept between calls so bytes already read are not lost if sniffing is
+	// retried after an error.
+	if d.buf == nil {
+		d.buf = bufio.NewReaderSize(d.in, size)
+	}
+
+	want := 1
+	if n := d.buf.Buffered(); n >= want {
+		want = n
+	}
+
+	for {
+		header, err := d.buf.Peek(want)
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		f, decided := sniffFormats(d.formats, header, err == io.EOF)
+		if !decided {
+			want = len(header) + 1
+			continue
+		}
+
+		if f == nil {
+			d.stream = d.buf
+			return nil
+		}
+
+		if d.dec, err = f.Open(d.buf); err != nil {
+			return err
+		}
+
+		d.stream = d.dec
+		return nil
+	}
+}
+
+// sniffFormats returns the first of the given formats the header matches, or
+// nil if it matches none.  If a format that comes before any match needs more
+// than the header to tell, and the stream has not ended, sniffFormats returns
+// false.
+func sniffFormats(formats []Format, header []byte, ended bool) (*Format, bool) {
+	for i := range formats {
+		f := &formats[i]
+
+		if len(header) >= f.HeaderSize || ended {
+			h := header
+			if len(h) > f.HeaderSize {
+				h = h[:f.HeaderSize]
+			}
+
+			if f.Sniff(h) {
+				return f, true
+			}
+
+			continue
+		}
+
+		// The header is too short to sniff, so the format can only be ruled
+		// out by its magic prefix.
+		n := len(header)
+		if n > len(f.Magic) {
+			n = len(f.Magic)
+		}
+
+		if f.Magic == nil || bytes.Equal(header[:n], f.Magic[:n]) {
+			return nil, false
+		}
+	}
+
+	return nil, true
+}
@@ -0,0 +1,222 @@
+package spipe_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	stdiotest "testing/iotest"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// bzip2 has no writer in the standard library, these are "bzip2 data\n" and an
+// empty stream as written by the bzip2 tool.
+const (
+	bzip2Data  = "425a68393141592653596d69522f000002d98000104000100034204410200022068621003000a3bcda7e2ee48a70a120dad2a45e"
+	bzip2Empty = "425a683917724538509000000000"
+)
+
+func gzipped(members ...string) []byte {
+	out := new(bytes.Buffer)
+
+	for _, m := range members {
+		w := gzip.NewWriter(out)
+		_, _ = w.Write([]byte(m))
+		_ = w.Close()
+	}
+
+	return out.Bytes()
+}
+
+func zlibbed(s string) []byte {
+	out := new(bytes.Buffer)
+	w := zlib.NewWriter(out)
+	_, _ = w.Write([]byte(s))
+	_ = w.Close()
+	return out.Bytes()
+}
+
+func unhex(s string) []byte {
+	out, _ := hex.DecodeString(s)
+	return out
+}
+
+// orderedCloser records its name in a shared log when it is closed.
+type orderedCloser struct {
+	io.Reader
+	name string
+	log  *[]string
+}
+
+func (o *orderedCloser) Close() error {
+	*o.log = append(*o.log, o.name)
+	return nil
+}
+
+func TestMultiReadCloser_Decompress(t *testing.T) {
+	Convey("MultiReadCloser.Decompress", t, func() {
+		Convey("mixed formats", func() {
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(strings.NewReader("plain data\n")),
+				ioutil.NopCloser(bytes.NewReader(gzipped("gzip data\n", "second member\n"))),
+				ioutil.NopCloser(bytes.NewReader(zlibbed("zlib data\n"))),
+				ioutil.NopCloser(bytes.NewReader(unhex(bzip2Data))),
+				ioutil.NopCloser(bytes.NewReader(unhex(bzip2Empty))),
+				ioutil.NopCloser(strings.NewReader("")),
+				ioutil.NopCloser(strings.NewReader("\x1f")),
+			).Decompress(spipe.GzipFormat, spipe.Bzip2Format, spipe.ZlibFormat)
+
+			out, err := ioutil.ReadAll(test)
+
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "plain data\n"+
+				"gzip data\nsecond member\n"+
+				"zlib data\n"+
+				"bzip2 data\n"+
+				"\x1f")
+		})
+
+		Convey("plain text with a zlib-like start", func() {
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(strings.NewReader("HKEY_LOCAL_MACHINE\\Software\n")),
+				ioutil.NopCloser(strings.NewReader("x^2\n")),
+			).Decompress()
+
+			out, err := ioutil.ReadAll(test)
+
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "HKEY_LOCAL_MACHINE\\Software\nx^2\n")
+		})
+
+		Convey("header split across reads", func() {
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(stdiotest.OneByteReader(bytes.NewReader(gzipped("gzip data\n")))),
+				ioutil.NopCloser(stdiotest.OneByteReader(bytes.NewReader(unhex(bzip2Data)))),
+			).Decompress()
+
+			out, err := ioutil.ReadAll(test)
+
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "gzip data\nbzip2 data\n")
+		})
+
+		Convey("short interactive input", func() {
+			r, w := io.Pipe()
+			defer w.Close()
+
+			go func() { _, _ = w.Write([]byte("hi\n")) }()
+
+			test := spipe.NewMultiReadCloser(r).Decompress()
+			done := make(chan string, 1)
+
+			go func() {
+				buf := make([]byte, 3)
+				n, _ := test.Read(buf)
+				done <- string(buf[:n])
+			}()
+
+			select {
+			case got := <-done:
+				So(got, ShouldEqual, "hi\n")
+			case <-time.After(time.Second):
+				t.Fatal("read blocked waiting for a full header")
+			}
+		})
+
+		Convey("selected formats", func() {
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(bytes.NewReader(gzipped("a"))),
+				ioutil.NopCloser(bytes.NewReader(zlibbed("b"))),
+			).Decompress(spipe.ZlibFormat)
+
+			out, err := ioutil.ReadAll(test)
+
+			So(err, ShouldBeNil)
+			So(bytes.HasPrefix(out, []byte{0x1f, 0x8b}), ShouldBeTrue)
+			So(bytes.HasSuffix(out, []byte("b")), ShouldBeTrue)
+		})
+
+		Convey("corrupt input", func() {
+			bad := gzipped("hello")
+			bad[len(bad)-1]++
+
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(bytes.NewReader(bad)),
+			).Decompress()
+
+			_, err := ioutil.ReadAll(test)
+			se := new(spipe.StreamError)
+
+			So(errors.As(err, &se), ShouldBeTrue)
+			So(se.Op, ShouldEqual, spipe.OpRead)
+		})
+
+		Convey("close order", func() {
+			var log []string
+
+			format := spipe.MagicFormat("test", []byte("TEST"), func(r io.Reader) (io.ReadCloser, error) {
+				return &orderedCloser{Reader: r, name: "decompressor", log: &log}, nil
+			})
+
+			newInput := func() io.ReadCloser {
+				return &orderedCloser{Reader: strings.NewReader("TEST data"), name: "input", log: &log}
+			}
+
+			Convey("on Close", func() {
+				test := spipe.NewMultiReadCloser(newInput()).Decompress(format)
+
+				_, _ = test.Read(make([]byte, 4))
+				So(test.Close(), ShouldBeNil)
+				So(log, ShouldResemble, []string{"decompressor", "input"})
+			})
+
+			Convey("with CloseImmediately", func() {
+				test := spipe.NewMultiReadCloser(newInput(), newInput()).
+					CloseImmediately(true).
+					Decompress(format)
+
+				buf := make([]byte, 10)
+				n, err := test.Read(buf)
+
+				So(err, ShouldBeNil)
+				So(string(buf[:n]), ShouldEqual, "TEST dataT")
+				So(log, ShouldResemble, []string{"decompressor", "input"})
+			})
+
+			Convey("never read", func() {
+				test := spipe.NewMultiReadCloser(newInput()).Decompress(format)
+
+				So(test.Close(), ShouldBeNil)
+				So(log, ShouldResemble, []string{"input"})
+			})
+		})
+	})
+}
+
+func TestLazyReadCloser_Decompress(t *testing.T) {
+	Convey("LazyReadCloser.Decompress", t, func() {
+		opened := 0
+		test := spipe.NewLazyReadCloser(func() (io.ReadCloser, error) {
+			opened++
+			return ioutil.NopCloser(bytes.NewReader(gzipped("lazy data"))), nil
+		}).Decompress()
+
+		So(opened, ShouldEqual, 0)
+
+		out, err := ioutil.ReadAll(test)
+
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "lazy data")
+		So(opened, ShouldEqual, 1)
+		So(test.Close(), ShouldBeNil)
+	})
+}
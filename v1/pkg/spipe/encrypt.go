@@ -0,0 +1,471 @@
+package spipe
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+var (
+	// ErrDecrypt is returned by a decrypt reader when a chunk fails
+	// authentication, which happens if the stream was encrypted with a
+	// different key, has been modified, or has had its chunks reordered.  It
+	// is also returned if the stream is not in the encrypted format.
+	ErrDecrypt = errors.New("spipe: encrypted chunk failed authentication")
+
+	// ErrTruncated is returned by a decrypt reader when the stream ends before
+	// its final chunk.
+	ErrTruncated = errors.New("spipe: encrypted stream truncated")
+
+	// ErrNonceSize is returned when an AEAD's nonce is too short to hold the
+	// chunk counter.
+	ErrNonceSize = errors.New("spipe: AEAD nonce size must be at least 8 bytes")
+)
+
+const (
+	// DefaultEncryptChunkSize is the default number of plaintext bytes sealed
+	// into each chunk by an EncryptWriter.
+	DefaultEncryptChunkSize = 64 * 1024
+
+	// MaxEncryptChunkSize is the largest chunk size an EncryptWriter will use
+	// and a decrypt reader will accept.
+	MaxEncryptChunkSize = 16 * 1024 * 1024
+)
+
+// The stream header is the magic bytes followed by a format version and the
+// random salt the stream's key is derived from.  Each chunk is a flag byte, the
+// big endian length of the sealed chunk, and the sealed chunk.
+//
+// The first chunk is sealed with the header ahead of its flag byte in its
+// additional data, so the header is authenticated along with the chunks.
+var (
+	encryptMagic = []byte("SPAE")
+	encryptInfo  = []byte("spipe encrypt v2")
+)
+
+const (
+	encryptVersion    = 2
+	encryptSaltLen    = 32
+	encryptHeadLen    = 5 + encryptSaltLen
+	encryptFinal      = 1
+	encryptFrameHead  = 5
+	encryptCounterLen = 8
+)
+
+// AEADFunc constructs an AEAD cipher from a key, such as
+// chacha20poly1305.New.
+type AEADFunc func(key []byte) (cipher.AEAD, error)
+
+// AESGCM is an AEADFunc for AES-GCM, taking a 16, 24 or 32 byte key.
+func AESGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// EncryptWriter defines an io.WriteCloser implementation that encrypts a
+// stream with an AEAD cipher, such as AES-GCM, in independently sealed chunks
+// so the stream can be decrypted as it is read.
+//
+// Every stream is sealed with its own key, derived with HKDF-SHA256 from the
+// given key and a random salt written in the stream header, so each chunk's
+// nonce is simply the chunk's position and chunks that are reordered fail
+// authentication.  The last chunk is marked as final in its additional data,
+// so a stream that has been cut short fails with ErrTruncated rather than
+// silently ending early.
+//
+// Close writes the final chunk but does not close the underlying writer.
+type EncryptWriter interface {
+	io.WriteCloser
+
+	// ChunkSize sets the number of plaintext bytes sealed into each chunk.  Has
+	// no effect once the first chunk has been written.  Defaults to
+	// DefaultEncryptChunkSize, and is capped at MaxEncryptChunkSize.
+	ChunkSize(int) EncryptWriter
+}
+
+// NewEncryptWriter returns a new EncryptWriter instance that writes the
+// stream, encrypted with the AEAD made by newAEAD from a key derived from the
+// given key, to the given writer.
+//
+// Returns the error from newAEAD if it rejects the key, or ErrNonceSize if the
+// AEAD's nonce is shorter than 8 bytes.
+func NewEncryptWriter(out io.Writer, key []byte, newAEAD AEADFunc) (EncryptWriter, error) {
+	if err := checkAEAD(key, newAEAD); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{
+		out:       out,
+		key:       append([]byte{}, key...),
+		newAEAD:   newAEAD,
+		chunkSize: DefaultEncryptChunkSize,
+	}, nil
+}
+
+// EncryptTransform returns a Transformer that encrypts the stream with the
+// given key and AEAD, as NewEncryptWriter does.  It can be used to encrypt a
+// single output of a SplitWriteCloser.
+//
+// Returns the error from newAEAD if it rejects the key, or ErrNonceSize if the
+// AEAD's nonce is shorter than 8 bytes.
+func EncryptTransform(key []byte, newAEAD AEADFunc) (Transformer, error) {
+	if err := checkAEAD(key, newAEAD); err != nil {
+		return nil, err
+	}
+
+	key = append([]byte{}, key...)
+
+	return TransformerFunc(func(out io.Writer) io.WriteCloser {
+		w, _ := NewEncryptWriter(out, key, newAEAD)
+		return w
+	}), nil
+}
+
+// checkAEAD returns an error if newAEAD cannot make a usable AEAD from key.
+func checkAEAD(key []byte, newAEAD AEADFunc) error {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return err
+	}
+
+	if aead.NonceSize() < encryptCounterLen {
+		return ErrNonceSize
+	}
+
+	return nil
+}
+
+// streamAEAD reads a stream header and returns the AEAD for the stream,
+// made by newAEAD from a key derived with HKDF-SHA256 from the given key and
+// the header's salt.
+func streamAEAD(key, header []byte, newAEAD AEADFunc) (cipher.AEAD, error) {
+	extract := hmac.New(sha256.New, header[len(header)-encryptSaltLen:])
+	extract.Write(key)
+	prk := extract.Sum(nil)
+
+	var derived, block []byte
+
+	for i := byte(1); len(derived) < len(key); i++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(block)
+		expand.Write(encryptInfo)
+		expand.Write([]byte{i})
+		block = expand.Sum(nil)
+		derived = append(derived, block...)
+	}
+
+	aead, err := newAEAD(derived[:len(key)])
+	if err != nil {
+		return nil, err
+	}
+
+	if aead.NonceSize() < encryptCounterLen {
+		return nil, ErrNonceSize
+	}
+
+	return aead, nil
+}
+
+// encryptAD returns the additional data for a chunk with the given flag,
+// which for the first chunk includes the stream header.
+func encryptAD(buf, header []byte, counter uint64, flag byte) []byte {
+	buf = buf[:0]
+
+	if counter == 0 {
+		buf = append(buf, header...)
+	}
+
+	return append(buf, flag)
+}
+
+type encryptWriter struct {
+	out       io.Writer
+	key       []byte
+	newAEAD   AEADFunc
+	aead      cipher.AEAD
+	chunkSize int
+	header    []byte
+	ad        []byte
+	nonce     []byte
+	counter   uint64
+
+	// pending holds plaintext not yet sealed.  A full chunk is held until more
+	// data arrives so the last chunk can be marked as final on Close.
+	pending []byte
+	frame   []byte
+
+	closed bool
+	err    error
+}
+
+func (e *encryptWriter) Write(p []byte) (n int, err error) {
+	if e.closed {
+		return 0, os.ErrClosed
+	}
+
+	if err = e.start(); err != nil {
+		return
+	}
+
+	for len(p) > 0 {
+		if len(e.pending) == e.chunkSize {
+			if err = e.seal(false); err != nil {
+				return
+			}
+		}
+
+		k := e.chunkSize - len(e.pending)
+		if k > len(p) {
+			k = len(p)
+		}
+
+		e.pending = append(e.pending, p[:k]...)
+		p = p[k:]
+		n += k
+	}
+
+	return
+}
+
+// Close seals and writes any held plaintext as the final chunk.
+func (e *encryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+
+	e.closed = true
+
+	if err := e.start(); err != nil {
+		return err
+	}
+
+	return e.seal(true)
+}
+
+func (e *encryptWriter) ChunkSize(n int) EncryptWriter {
+	if e.nonce != nil {
+		return e
+	}
+
+	if n < 1 {
+		n = 1
+	} else if n > MaxEncryptChunkSize {
+		n = MaxEncryptChunkSize
+	}
+
+	e.chunkSize = n
+	return e
+}
+
+// start writes the stream header, choosing the salt and deriving the stream's
+// key, if it has not already been written.
+func (e *encryptWriter) start() error {
+	if e.err != nil || e.nonce != nil {
+		return e.err
+	}
+
+	header := make([]byte, encryptHeadLen)
+	copy(header, encryptMagic)
+	header[len(encryptMagic)] = encryptVersion
+
+	if _, e.err = io.ReadFull(rand.Reader, header[len(encryptMagic)+1:]); e.err != nil {
+		return e.err
+	}
+
+	if e.aead, e.err = streamAEAD(e.key, header, e.newAEAD); e.err != nil {
+		return e.err
+	}
+
+	if e.err = writeRecordTo(e.out, header); e.err != nil {
+		return e.err
+	}
+
+	e.header = header
+	e.nonce = make([]byte, e.aead.NonceSize())
+	e.pending = make([]byte, 0, e.chunkSize)
+
+	return nil
+}
+
+func (e *encryptWriter) seal(final bool) error {
+	if e.err != nil {
+		return e.err
+	}
+
+	flag := byte(0)
+	if final {
+		flag = encryptFinal
+	}
+
+	binary.BigEndian.PutUint64(e.nonce[len(e.nonce)-encryptCounterLen:], e.counter)
+	e.ad = encryptAD(e.ad, e.header, e.counter, flag)
+	e.counter++
+
+	e.frame = append(e.frame[:0], flag, 0, 0, 0, 0)
+	e.frame = e.aead.Seal(e.frame, e.nonce, e.pending, e.ad)
+	binary.BigEndian.PutUint32(e.frame[1:encryptFrameHead], uint32(len(e.frame)-encryptFrameHead))
+
+	e.pending = e.pending[:0]
+	e.err = writeRecordTo(e.out, e.frame)
+
+	return e.err
+}
+
+// NewDecryptReader returns a reader that decrypts a stream written by an
+// EncryptWriter using the same key and AEAD constructor.
+//
+// Decrypted data is only returned once the chunk containing it has been
+// authenticated.  The reader returns ErrDecrypt if a chunk fails
+// authentication and ErrTruncated if the stream ends before its final chunk.
+func NewDecryptReader(in io.Reader, key []byte, newAEAD AEADFunc) io.Reader {
+	return &decryptReader{in: in, key: append([]byte{}, key...), newAEAD: newAEAD}
+}
+
+type decryptReader struct {
+	in      io.Reader
+	key     []byte
+	newAEAD AEADFunc
+	aead    cipher.AEAD
+	header  []byte
+	ad      []byte
+	nonce   []byte
+	counter uint64
+
+	// plain holds decrypted bytes not yet returned.
+	plain []byte
+	buf   []byte
+	head  [encryptFrameHead]byte
+
+	done bool
+	err  error
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		if d.done {
+			d.err = d.checkEnd()
+			continue
+		}
+
+		d.err = d.next()
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+
+	return n, nil
+}
+
+// next reads and opens the next chunk, reading the stream header first if
+// needed.
+func (d *decryptReader) next() error {
+	if d.nonce == nil {
+		header := make([]byte, encryptHeadLen)
+		version := len(encryptMagic)
+
+		if err := d.readFull(header[:version+1]); err != nil {
+			return err
+		}
+
+		if !bytes.HasPrefix(header, encryptMagic) || header[version] != encryptVersion {
+			return ErrDecrypt
+		}
+
+		if err := d.readFull(header[version+1:]); err != nil {
+			return err
+		}
+
+		aead, err := streamAEAD(d.key, header, d.newAEAD)
+		if err != nil {
+			return err
+		}
+
+		d.aead = aead
+		d.header = header
+		d.nonce = make([]byte, aead.NonceSize())
+	}
+
+	if err := d.readFull(d.head[:]); err != nil {
+		return err
+	}
+
+	flag := d.head[0]
+	size := binary.BigEndian.Uint32(d.head[1:])
+
+	if flag&^encryptFinal != 0 || size > MaxEncryptChunkSize+uint32(d.aead.Overhead()) {
+		return ErrDecrypt
+	}
+
+	if cap(d.buf) < int(size) {
+		d.buf = make([]byte, size)
+	}
+
+	sealed := d.buf[:size]
+
+	if err := d.readFull(sealed); err != nil {
+		return err
+	}
+
+	binary.BigEndian.PutUint64(d.nonce[len(d.nonce)-encryptCounterLen:], d.counter)
+	d.ad = encryptAD(d.ad, d.header, d.counter, flag)
+	d.counter++
+
+	plain, err := d.aead.Open(sealed[:0], d.nonce, sealed, d.ad)
+	if err != nil {
+		return ErrDecrypt
+	}
+
+	d.plain = plain
+	d.done = flag == encryptFinal
+
+	return nil
+}
+
+// checkEnd returns io.EOF if the stream ends after its final chunk, or
+// ErrDecrypt if there is data following it.
+func (d *decryptReader) checkEnd() error {
+	n, err := d.in.Read(d.head[:1])
+
+	for n == 0 && err == nil {
+		n, err = d.in.Read(d.head[:1])
+	}
+
+	if n > 0 {
+		return ErrDecrypt
+	}
+
+	if err == io.EOF {
+		return io.EOF
+	}
+
+	return err
+}
+
+// readFull fills p from the input, reporting a stream that ends part way
+// through as truncated.
+func (d *decryptReader) readFull(p []byte) error {
+	if _, err := io.ReadFull(d.in, p); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrTruncated
+		}
+
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,299 @@
+package spipe_test
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func encrypted(key []byte, chunkSize int, data string) []byte {
+	out := new(bytes.Buffer)
+	w, _ := spipe.NewEncryptWriter(out, key, spipe.AESGCM)
+	w.ChunkSize(chunkSize)
+	_, _ = w.Write([]byte(data))
+	_ = w.Close()
+	return out.Bytes()
+}
+
+// encryptedChunks splits an encrypted stream into its header and chunks.
+func encryptedChunks(stream []byte) (header []byte, chunks [][]byte) {
+	header, stream = stream[:37], stream[37:]
+
+	for len(stream) > 0 {
+		size := 5 + int(binary.BigEndian.Uint32(stream[1:5]))
+		chunks = append(chunks, stream[:size])
+		stream = stream[size:]
+	}
+
+	return
+}
+
+func TestEncryptWriter(t *testing.T) {
+	Convey("EncryptWriter", t, func() {
+		key := testKey(1)
+		input := "the quick brown fox jumps over the lazy dog"
+
+		Convey("round trip", func() {
+			for _, size := range []int{1, 4, len(input), 1024} {
+				stream := encrypted(key, size, input)
+
+				So(bytes.Contains(stream, []byte("quick")), ShouldBeFalse)
+
+				out, err := ioutil.ReadAll(spipe.NewDecryptReader(bytes.NewReader(stream), key, spipe.AESGCM))
+				So(err, ShouldBeNil)
+				So(string(out), ShouldEqual, input)
+			}
+		})
+
+		Convey("empty stream", func() {
+			stream := encrypted(key, 16, "")
+
+			_, chunks := encryptedChunks(stream)
+			So(len(chunks), ShouldEqual, 1)
+
+			out, err := ioutil.ReadAll(spipe.NewDecryptReader(bytes.NewReader(stream), key, spipe.AESGCM))
+			So(err, ShouldBeNil)
+			So(len(out), ShouldEqual, 0)
+		})
+
+		Convey("full final chunk", func() {
+			_, chunks := encryptedChunks(encrypted(key, 4, "abcdefgh"))
+			So(len(chunks), ShouldEqual, 2)
+		})
+
+		Convey("unique salts", func() {
+			a, chunksA := encryptedChunks(encrypted(key, 16, input))
+			b, chunksB := encryptedChunks(encrypted(key, 16, input))
+			So(bytes.Equal(a, b), ShouldBeFalse)
+			So(bytes.Equal(chunksA[0], chunksB[0]), ShouldBeFalse)
+		})
+
+		Convey("header authenticated with the first chunk", func() {
+			var ads [][]byte
+			recording := func(key []byte) (cipher.AEAD, error) {
+				aead, err := spipe.AESGCM(key)
+				return adRecorder{aead, &ads}, err
+			}
+
+			out := new(bytes.Buffer)
+			w, _ := spipe.NewEncryptWriter(out, key, recording)
+			w.ChunkSize(4)
+			_, _ = w.Write([]byte("abcdefgh"))
+			So(w.Close(), ShouldBeNil)
+
+			header, _ := encryptedChunks(out.Bytes())
+			So(len(ads), ShouldEqual, 2)
+			So(string(ads[0]), ShouldEqual, string(header)+"\x00")
+			So(string(ads[1]), ShouldEqual, "\x01")
+		})
+
+		Convey("write after close", func() {
+			w, _ := spipe.NewEncryptWriter(new(bytes.Buffer), key, spipe.AESGCM)
+			So(w.Close(), ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			_, err := w.Write([]byte("a"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("output errors are sticky", func() {
+			out := &WriteCloser{WriteErrors: []error{nil, io.ErrClosedPipe}}
+			w, _ := spipe.NewEncryptWriter(out, key, spipe.AESGCM)
+			w.ChunkSize(2)
+
+			_, err := w.Write([]byte("abcde"))
+			So(err, ShouldEqual, io.ErrClosedPipe)
+			So(w.Close(), ShouldEqual, io.ErrClosedPipe)
+		})
+
+		Convey("short nonces", func() {
+			_, err := spipe.NewEncryptWriter(new(bytes.Buffer), key, shortNonceAEAD)
+			So(err, ShouldEqual, spipe.ErrNonceSize)
+
+			_, err = spipe.EncryptTransform(key, shortNonceAEAD)
+			So(err, ShouldEqual, spipe.ErrNonceSize)
+		})
+
+		Convey("invalid keys", func() {
+			_, err := spipe.NewEncryptWriter(new(bytes.Buffer), key[:5], spipe.AESGCM)
+			So(err, ShouldNotBeNil)
+
+			_, err = spipe.EncryptTransform(key[:5], spipe.AESGCM)
+			So(err, ShouldNotBeNil)
+		})
+	})
+}
+
+// shortNonce reports a nonce size too small to hold the chunk counter.
+type shortNonce struct {
+	cipher.AEAD
+}
+
+func (shortNonce) NonceSize() int {
+	return 4
+}
+
+// adRecorder records the additional data of every sealed chunk.
+type adRecorder struct {
+	cipher.AEAD
+	ads *[][]byte
+}
+
+func (a adRecorder) Seal(dst, nonce, plain, ad []byte) []byte {
+	*a.ads = append(*a.ads, append([]byte(nil), ad...))
+	return a.AEAD.Seal(dst, nonce, plain, ad)
+}
+
+func shortNonceAEAD(key []byte) (cipher.AEAD, error) {
+	aead, err := spipe.AESGCM(key)
+	return shortNonce{aead}, err
+}
+
+func TestDecryptReader(t *testing.T) {
+	Convey("DecryptReader", t, func() {
+		key := testKey(1)
+		input := "the quick brown fox jumps over the lazy dog"
+		stream := encrypted(key, 8, input)
+		header, chunks := encryptedChunks(stream)
+
+		read := func(stream []byte, key []byte) ([]byte, error) {
+			return ioutil.ReadAll(spipe.NewDecryptReader(bytes.NewReader(stream), key, spipe.AESGCM))
+		}
+
+		join := func(parts ...[]byte) []byte {
+			return bytes.Join(parts, nil)
+		}
+
+		Convey("wrong key", func() {
+			_, err := read(stream, testKey(2))
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("truncated at a chunk boundary", func() {
+			out, err := read(join(header, chunks[0], chunks[1]), key)
+			So(err, ShouldEqual, spipe.ErrTruncated)
+			So(string(out), ShouldEqual, input[:16])
+		})
+
+		Convey("truncated within a chunk", func() {
+			_, err := read(stream[:len(stream)-1], key)
+			So(err, ShouldEqual, spipe.ErrTruncated)
+		})
+
+		Convey("truncated header", func() {
+			_, err := read(stream[:4], key)
+			So(err, ShouldEqual, spipe.ErrTruncated)
+
+			_, err = read(nil, key)
+			So(err, ShouldEqual, spipe.ErrTruncated)
+		})
+
+		Convey("modified salt", func() {
+			bad := append([]byte(nil), header...)
+			bad[len(bad)-1]++
+
+			_, err := read(join(bad, join(chunks...)), key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("reordered chunks", func() {
+			_, err := read(join(header, chunks[1], chunks[0], join(chunks[2:]...)), key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("dropped chunk", func() {
+			_, err := read(join(header, chunks[0], join(chunks[2:]...)), key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("forged final flag", func() {
+			forged := append([]byte(nil), chunks[1]...)
+			forged[0] = 1
+
+			_, err := read(join(header, chunks[0], forged), key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("modified chunk", func() {
+			bad := append([]byte(nil), stream...)
+			bad[len(header)+8]++
+
+			_, err := read(bad, key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("trailing data", func() {
+			out, err := read(join(stream, chunks[0]), key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+			So(string(out), ShouldEqual, input)
+		})
+
+		Convey("not encrypted", func() {
+			_, err := read([]byte("plain text, not encrypted at all"), key)
+			So(err, ShouldEqual, spipe.ErrDecrypt)
+		})
+
+		Convey("small reads", func() {
+			r := spipe.NewDecryptReader(bytes.NewReader(stream), key, spipe.AESGCM)
+			out := new(bytes.Buffer)
+			buf := make([]byte, 3)
+
+			for {
+				n, err := r.Read(buf)
+				out.Write(buf[:n])
+
+				if err == io.EOF {
+					break
+				}
+
+				So(err, ShouldBeNil)
+			}
+
+			So(out.String(), ShouldEqual, input)
+		})
+	})
+}
+
+func TestEncryptTransform(t *testing.T) {
+	Convey("EncryptTransform", t, func() {
+		key := testKey(3)
+		input := strings.Repeat("log line\n", 100)
+
+		enc, err := spipe.EncryptTransform(key, spipe.AESGCM)
+		So(err, ShouldBeNil)
+
+		local, offsite := new(WriteCloser), new(closeOrderWC)
+		test := spipe.NewSplitWriteCloser(local, offsite).Transform(1, enc)
+
+		_, err = test.Write([]byte(input))
+		So(err, ShouldBeNil)
+		So(test.Close(), ShouldBeNil)
+
+		So(string(local.WrittenBytes), ShouldEqual, input)
+
+		Convey("read back through a multi-reader", func() {
+			r := spipe.NewMultiReader(
+				strings.NewReader("header\n"),
+				spipe.NewDecryptReader(bytes.NewReader(offsite.atClose), key, spipe.AESGCM),
+				spipe.NewDecryptReader(bytes.NewReader(encrypted(key, 5, "footer\n")), key, spipe.AESGCM),
+			)
+
+			out, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "header\n"+input+"footer\n")
+		})
+	})
+}
@@ -0,0 +1,64 @@
+package spipe
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// NewCommandWriteCloser starts the given command and returns an io.WriteCloser
+// that writes to its standard input, so that a subprocess can be used as an
+// output of a split writer, much like process substitution in a shell.
+//
+// The command's standard input must not already be set.  Its standard output
+// and error are left as configured, and are discarded if unset.
+//
+// Close closes the command's standard input and waits for it to exit.  If the
+// command exits with a non-zero status, Close returns the *exec.ExitError.  A
+// command that exits before reading all of its input causes writes to fail,
+// typically with a broken pipe error.
+func NewCommandWriteCloser(cmd *exec.Cmd) (io.WriteCloser, error) {
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err = cmd.Start(); err != nil {
+		_ = stdin.Close()
+		return nil, err
+	}
+
+	return &commandWriteCloser{cmd: cmd, stdin: stdin}, nil
+}
+
+type commandWriteCloser struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	closed bool
+	err    error
+}
+
+func (c *commandWriteCloser) Write(p []byte) (int, error) {
+	if c.closed {
+		return 0, os.ErrClosed
+	}
+
+	return c.stdin.Write(p)
+}
+
+// Close closes the command's standard input and waits for it to exit.  Later
+// calls return the same result without waiting again.
+func (c *commandWriteCloser) Close() error {
+	if c.closed {
+		return c.err
+	}
+
+	c.closed = true
+	c.err = c.stdin.Close()
+
+	if err := c.cmd.Wait(); err != nil {
+		c.err = err
+	}
+
+	return c.err
+}
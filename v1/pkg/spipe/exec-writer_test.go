@@ -0,0 +1,86 @@
+package spipe_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestCommandWriteCloser(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	Convey("CommandWriteCloser", t, func() {
+		Convey("feeds the command", func() {
+			out := new(bytes.Buffer)
+			cmd := exec.Command("sh", "-c", "tr a-z A-Z")
+			cmd.Stdout = out
+
+			test, err := spipe.NewCommandWriteCloser(cmd)
+			So(err, ShouldBeNil)
+
+			_, err = test.Write([]byte("hello"))
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+			So(out.String(), ShouldEqual, "HELLO")
+
+			So(test.Close(), ShouldBeNil)
+
+			_, err = test.Write([]byte("a"))
+			So(err, ShouldEqual, os.ErrClosed)
+		})
+
+		Convey("exit status", func() {
+			test, err := spipe.NewCommandWriteCloser(exec.Command("sh", "-c", "cat >/dev/null; exit 3"))
+			So(err, ShouldBeNil)
+
+			_, _ = test.Write([]byte("hello"))
+			err = test.Close()
+
+			exit := new(exec.ExitError)
+			So(errors.As(err, &exit), ShouldBeTrue)
+			So(exit.ExitCode(), ShouldEqual, 3)
+			So(test.Close(), ShouldEqual, err)
+		})
+
+		Convey("failing start", func() {
+			_, err := spipe.NewCommandWriteCloser(exec.Command("/does/not/exist"))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("as split writer outputs", func() {
+			good, bad := new(bytes.Buffer), new(bytes.Buffer)
+
+			goodCmd := exec.Command("sh", "-c", "cat")
+			goodCmd.Stdout = good
+			badCmd := exec.Command("sh", "-c", "cat; exit 1")
+			badCmd.Stdout = bad
+
+			a, _ := spipe.NewCommandWriteCloser(goodCmd)
+			b, _ := spipe.NewCommandWriteCloser(badCmd)
+
+			primary := new(WriteCloser)
+			test := spipe.NewSplitWriteCloser(primary, a, b).Names("primary", "cat", "cat; exit 1")
+
+			_, err := test.Write([]byte("data"))
+			So(err, ShouldBeNil)
+
+			err = test.Close()
+			se := new(spipe.StreamError)
+
+			So(errors.As(err, &se), ShouldBeTrue)
+			So(se.Index, ShouldEqual, 2)
+			So(se.Name, ShouldEqual, "cat; exit 1")
+			So(good.String(), ShouldEqual, "data")
+			So(bad.String(), ShouldEqual, "data")
+		})
+	})
+}
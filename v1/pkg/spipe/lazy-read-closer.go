@@ -0,0 +1,104 @@
+package spipe
+
+import (
+	"io"
+	"os"
+)
+
+// Opener opens a stream for reading.
+type Opener func() (io.ReadCloser, error)
+
+// LazyReadCloser defines an io.ReadCloser implementation that defers opening
+// its underlying stream until the first call to Read.
+//
+// This allows large numbers of inputs, such as files, to be given to a
+// MultiReadCloser without holding all of them open at once.
+type LazyReadCloser interface {
+	io.ReadCloser
+
+	// Decompress wraps the stream, once opened, in a reader that recognises
+	// its compression format from its first bytes and decompresses it.  A
+	// stream in none of the given formats, or of DefaultFormats if none are
+	// given, is read unchanged.  The decompressor is closed before the stream.
+	Decompress(formats ...Format) LazyReadCloser
+}
+
+// NewLazyReadCloser returns a new LazyReadCloser instance that will use the
+// given Opener to open its underlying stream on the first call to Read.
+func NewLazyReadCloser(open Opener) LazyReadCloser {
+	return &lazyReadCloser{open: open}
+}
+
+type lazyReadCloser struct {
+	open   Opener
+	stream io.ReadCloser
+	closed bool
+
+	// eof records whether the stream has returned io.EOF, after which it is not
+	// read again.
+	eof bool
+
+	// formats is non-nil if the stream should be decompressed.
+	formats []Format
+}
+
+// Read opens the underlying stream if it has not yet been opened, then reads
+// from it.
+//
+// If the stream fails to open, the error from the Opener is returned.  Once the
+// stream has returned io.EOF, later reads return io.EOF without reading it.
+func (l *lazyReadCloser) Read(p []byte) (n int, err error) {
+	if l.closed {
+		return 0, os.ErrClosed
+	}
+
+	if l.eof {
+		return 0, io.EOF
+	}
+
+	if l.stream == nil {
+		// Opened into a local so a failed open, which may return a typed nil
+		// such as a nil *os.File, is retried rather than closed.
+		stream, err := l.open()
+		if err != nil {
+			return 0, err
+		}
+
+		if l.formats != nil {
+			stream = newDecompressReadCloser(stream, l.formats)
+		}
+
+		l.stream = stream
+	}
+
+	n, err = l.stream.Read(p)
+	l.eof = err == io.EOF
+
+	return
+}
+
+func (l *lazyReadCloser) Decompress(formats ...Format) LazyReadCloser {
+	if formats == nil {
+		formats = DefaultFormats()
+	}
+
+	l.formats = formats
+	return l
+}
+
+// Close closes the underlying stream if it was opened.
+//
+// If the stream was never opened, it will not be opened by Close.
+func (l *lazyReadCloser) Close() error {
+	if l.closed {
+		return nil
+	}
+
+	l.closed = true
+
+	if l.stream == nil {
+		return nil
+	}
+
+	return l.stream.Close()
+}
@@ -0,0 +1,93 @@
+package spipe_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestLazyReadCloser(t *testing.T) {
+	Convey("LazyReadCloser", t, func() {
+		opens, closes := 0, 0
+		open := func() (io.ReadCloser, error) {
+			opens++
+			return testRc{
+				Reader: strings.NewReader("abc"),
+				cl:     func() error { closes++; return nil },
+			}, nil
+		}
+
+		Convey("opens on first read", func() {
+			test := spipe.NewLazyReadCloser(open)
+			So(opens, ShouldEqual, 0)
+
+			buff := make([]byte, 3)
+			n, err := test.Read(buff)
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 3)
+			So(opens, ShouldEqual, 1)
+
+			_, _ = test.Read(buff)
+			So(opens, ShouldEqual, 1)
+
+			So(test.Close(), ShouldBeNil)
+			So(closes, ShouldEqual, 1)
+
+			_, err = test.Read(buff)
+			So(err, ShouldEqual, os.ErrClosed)
+		})
+
+		Convey("close without read", func() {
+			test := spipe.NewLazyReadCloser(open)
+
+			So(test.Close(), ShouldBeNil)
+			So(opens, ShouldEqual, 0)
+			So(closes, ShouldEqual, 0)
+		})
+
+		Convey("failing open", func() {
+			test := spipe.NewLazyReadCloser(func() (io.ReadCloser, error) {
+				return nil, errors.New("nope")
+			})
+
+			_, err := test.Read(make([]byte, 1))
+
+			So(err, ShouldResemble, errors.New("nope"))
+			So(test.Close(), ShouldBeNil)
+		})
+
+		Convey("failing file open", func() {
+			test := spipe.NewLazyReadCloser(func() (io.ReadCloser, error) {
+				return os.Open("does/not/exist")
+			})
+
+			_, err := test.Read(make([]byte, 1))
+
+			So(os.IsNotExist(err), ShouldBeTrue)
+			So(test.Close(), ShouldBeNil)
+		})
+
+		Convey("as a MultiReadCloser input", func() {
+			test := spipe.NewMultiReadCloser(
+				spipe.NewLazyReadCloser(open),
+				spipe.NewLazyReadCloser(open),
+			).CloseImmediately(true)
+
+			buff := make([]byte, 4)
+			n, err := test.Read(buff)
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 4)
+			So(opens, ShouldEqual, 2)
+			So(closes, ShouldEqual, 1)
+			So(test.Close(), ShouldBeNil)
+		})
+	})
+}
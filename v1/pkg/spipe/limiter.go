@@ -0,0 +1,158 @@
+package spipe
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Limiter is a token bucket that limits the rate at which bytes pass through
+// spipe readers and writers.
+//
+// The bucket refills at the configured rate up to a maximum of burst bytes.
+// A Limiter may be shared between several readers and writers, in which case
+// the limit applies to their combined throughput.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter returns a new Limiter that allows bytesPerSecond bytes per second
+// on average, with bursts of up to burst bytes.  The bucket starts full.
+//
+// A rate of 0 or less places no limit on throughput.  A burst of less than 1
+// is treated as 1.
+func NewLimiter(bytesPerSecond, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:   float64(bytesPerSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Burst returns the maximum number of bytes the limiter allows at once.
+func (l *Limiter) Burst() int {
+	return int(l.burst)
+}
+
+// WaitN blocks until the limiter allows n bytes through, or the given context
+// is done.
+//
+// Requests for more than Burst bytes are allowed, and wait for as long as it
+// takes to refill the bucket by the requested amount.  If the context is done
+// before the wait is over, WaitN returns the context's error and the bytes are
+// given back to the limiter.
+func (l *Limiter) WaitN(ctx context.Context, n int) error {
+	return l.wait(ctx, n, true)
+}
+
+// chargeN takes n bytes that have already passed through from the limiter and
+// blocks until the limiter would have allowed them, or the given context is
+// done.  Unlike WaitN, the bytes stay charged when the context is done, so
+// data read before a wait is cancelled still counts against the limit.
+func (l *Limiter) chargeN(ctx context.Context, n int) error {
+	return l.wait(ctx, n, false)
+}
+
+// wait takes n bytes from the limiter and blocks until they are allowed
+// through, or the given context is done.  If refund is set, a done context
+// leaves the limiter untouched.
+func (l *Limiter) wait(ctx context.Context, n int, refund bool) error {
+	if err := ctx.Err(); err != nil && refund {
+		return err
+	}
+
+	if n <= 0 || l.rate <= 0 {
+		return ctx.Err()
+	}
+
+	l.mu.Lock()
+	l.refill()
+	l.tokens -= float64(n)
+	wait := time.Duration(-l.tokens / l.rate * float64(time.Second))
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		if refund {
+			return nil
+		}
+
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		if refund {
+			l.mu.Lock()
+			l.refill()
+			l.tokens += float64(n)
+			if l.tokens > l.burst {
+				l.tokens = l.burst
+			}
+			l.mu.Unlock()
+		}
+
+		return ctx.Err()
+	}
+}
+
+// refill adds the tokens accumulated since the last refill.  Must be called
+// with mu held.
+func (l *Limiter) refill() {
+	now := time.Now()
+
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	l.last = now
+
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+}
+
+// limiterAt returns the limiter at the given position in the given list, or nil
+// if there is none.
+func limiterAt(limits []*Limiter, index int) *Limiter {
+	if index < len(limits) {
+		return limits[index]
+	}
+
+	return nil
+}
+
+// setLimiter sets the limiter at the given position in the given list of
+// limiters for count outputs, creating the list if needed.  Positions outside
+// the outputs are ignored.
+func setLimiter(limits []*Limiter, count, index int, limiter *Limiter) []*Limiter {
+	if index < 0 || index >= count {
+		return limits
+	}
+
+	if limits == nil {
+		limits = make([]*Limiter, count)
+	}
+
+	limits[index] = limiter
+	return limits
+}
+
+// orBackground returns the given context, or context.Background if it is nil.
+func orBackground(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+
+	return ctx
+}
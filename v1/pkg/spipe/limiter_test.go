@@ -0,0 +1,196 @@
+package spipe_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestLimiter_WaitN(t *testing.T) {
+	Convey("Limiter.WaitN", t, func() {
+		Convey("within burst", func() {
+			test := spipe.NewLimiter(10, 100)
+			start := time.Now()
+
+			So(test.WaitN(context.Background(), 100), ShouldBeNil)
+			So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+		})
+
+		Convey("beyond burst", func() {
+			test := spipe.NewLimiter(1000, 10)
+			start := time.Now()
+
+			So(test.WaitN(context.Background(), 10), ShouldBeNil)
+			So(test.WaitN(context.Background(), 100), ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 90*time.Millisecond)
+		})
+
+		Convey("unlimited", func() {
+			test := spipe.NewLimiter(0, 1)
+			start := time.Now()
+
+			So(test.WaitN(context.Background(), 1<<20), ShouldBeNil)
+			So(time.Since(start), ShouldBeLessThan, 50*time.Millisecond)
+		})
+
+		Convey("cancelled", func() {
+			test := spipe.NewLimiter(1, 1)
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+
+			So(test.WaitN(ctx, 1), ShouldBeNil)
+			So(errors.Is(test.WaitN(ctx, 100), context.DeadlineExceeded), ShouldBeTrue)
+			So(errors.Is(test.WaitN(ctx, 0), context.DeadlineExceeded), ShouldBeTrue)
+		})
+
+		Convey("burst floor", func() {
+			So(spipe.NewLimiter(10, 0).Burst(), ShouldEqual, 1)
+		})
+	})
+}
+
+func TestSplitWriter_Limit(t *testing.T) {
+	Convey("SplitWriter.Limit", t, func() {
+		Convey("throttles the limited output", func() {
+			a, b := new(strings.Builder), new(strings.Builder)
+			test := spipe.NewSplitWriter(a, b).Limit(1, spipe.NewLimiter(1000, 10))
+			start := time.Now()
+
+			_, err := test.Write([]byte("0123456789"))
+			So(err, ShouldBeNil)
+			_, err = test.WriteString(strings.Repeat("x", 50))
+			So(err, ShouldBeNil)
+
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 40*time.Millisecond)
+			So(b.String(), ShouldEqual, a.String())
+		})
+
+		Convey("cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			test := spipe.NewSplitWriter(new(strings.Builder), new(strings.Builder)).
+				Limit(1, spipe.NewLimiter(1, 1)).
+				Context(ctx)
+
+			_, err := test.Write([]byte("hello"))
+			se := new(spipe.StreamError)
+
+			So(errors.As(err, &se), ShouldBeTrue)
+			So(se.Index, ShouldEqual, 1)
+			So(se.Err, ShouldEqual, context.Canceled)
+
+			Convey("ignored", func() {
+				test.IgnoreErrors(true)
+
+				_, err := test.Write([]byte("hello"))
+				So(err, ShouldBeNil)
+				So(test.WriteByte('!'), ShouldBeNil)
+			})
+		})
+	})
+}
+
+func TestSplitWriteCloser_Limit(t *testing.T) {
+	Convey("SplitWriteCloser.Limit", t, func() {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		test := spipe.NewSplitWriteCloser(new(WriteCloser), new(WriteCloser)).
+			Limit(0, spipe.NewLimiter(1, 1)).
+			Context(ctx)
+
+		_, err := test.Write([]byte("hello"))
+		se := new(spipe.StreamError)
+
+		So(errors.As(err, &se), ShouldBeTrue)
+		So(se.Role, ShouldEqual, spipe.RolePrimary)
+		So(se.Err, ShouldEqual, context.Canceled)
+
+		Convey("positions outside the outputs are ignored", func() {
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), new(WriteCloser)).
+				Limit(2, spipe.NewLimiter(1, 1)).
+				Limit(-1, spipe.NewLimiter(1, 1)).
+				Context(ctx)
+
+			_, err := test.Write([]byte("hello"))
+			So(err, ShouldBeNil)
+		})
+	})
+}
+
+func TestMultiReader_Limit(t *testing.T) {
+	Convey("MultiReader.Limit", t, func() {
+		Convey("throttles reads", func() {
+			test := spipe.NewMultiReader(
+				strings.NewReader(strings.Repeat("a", 30)),
+				strings.NewReader(strings.Repeat("b", 30)),
+			).Limit(spipe.NewLimiter(1000, 10))
+			start := time.Now()
+
+			out, err := ioutil.ReadAll(test)
+
+			So(err, ShouldBeNil)
+			So(len(out), ShouldEqual, 60)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 40*time.Millisecond)
+		})
+
+		Convey("cancelled", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+
+			test := spipe.NewMultiReader(strings.NewReader("hello")).
+				Limit(spipe.NewLimiter(1, 1)).
+				Context(ctx)
+
+			_, err := test.Read(make([]byte, 5))
+			So(err, ShouldEqual, context.Canceled)
+		})
+
+		Convey("cancelled reads stay charged", func() {
+			limiter := spipe.NewLimiter(100, 10)
+			ctx, cancel := context.WithCancel(context.Background())
+
+			test := spipe.NewMultiReader(strings.NewReader(strings.Repeat("a", 20))).
+				Limit(limiter).
+				Context(ctx)
+
+			n, err := test.Read(make([]byte, 10))
+			So(n, ShouldEqual, 10)
+			So(err, ShouldBeNil)
+
+			// The second read waits for the bucket to refill, and is cancelled
+			// after the bytes were handed over.
+			time.AfterFunc(10*time.Millisecond, cancel)
+			n, err = test.Read(make([]byte, 10))
+			So(n, ShouldEqual, 10)
+			So(err, ShouldEqual, context.Canceled)
+
+			start := time.Now()
+			So(limiter.WaitN(context.Background(), 1), ShouldBeNil)
+			So(time.Since(start), ShouldBeGreaterThanOrEqualTo, 50*time.Millisecond)
+		})
+	})
+}
+
+func TestMultiReadCloser_Limit(t *testing.T) {
+	Convey("MultiReadCloser.Limit", t, func() {
+		test := spipe.NewMultiReadCloser(
+			ioutil.NopCloser(strings.NewReader("hello")),
+			ioutil.NopCloser(strings.NewReader("world")),
+		).Limit(spipe.NewLimiter(1000, 2))
+
+		out, err := ioutil.ReadAll(test)
+
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "helloworld")
+	})
+}
@@ -0,0 +1,205 @@
+package spipe
+
+import (
+	"io"
+	"sync"
+	"time"
+)
+
+// Observation describes a single call made by a spipe reader or writer to one
+// of its inputs or outputs.
+type Observation struct {
+	// Role is the part the stream plays.
+	Role Role
+
+	// Index is the position of the stream in the list of streams the reader or
+	// writer was constructed with.
+	Index int
+
+	// Name is the name given to the stream, if any.
+	Name string
+
+	// Op is the operation that was performed.
+	Op string
+
+	// Bytes is the number of bytes read or written by the call.
+	Bytes int
+
+	// Err is the error returned by the call.  io.EOF is not counted as an error
+	// in Metrics, but is still passed to observers.
+	Err error
+
+	// Latency is how long the call took.
+	Latency time.Duration
+}
+
+// Observer receives an Observation for every call an instrumented reader or
+// writer makes to its inputs or outputs.
+//
+// Observers are called synchronously from the read or write path, and should
+// return quickly.  They can be used to bridge metrics to systems such as
+// Prometheus or expvar.
+type Observer interface {
+	Observe(Observation)
+}
+
+// ObserverFunc adapts a function to the Observer interface.
+type ObserverFunc func(Observation)
+
+// Observe calls f(o).
+func (f ObserverFunc) Observe(o Observation) {
+	f(o)
+}
+
+// StreamMetrics holds the counters for a single input or output.
+type StreamMetrics struct {
+	// Role is the part the stream plays.
+	Role Role
+
+	// Index is the position of the stream in the list of streams the reader or
+	// writer was constructed with.
+	Index int
+
+	// Name is the name given to the stream, if any.
+	Name string
+
+	// Bytes is the total number of bytes read from or written to the stream.
+	Bytes int64
+
+	// Calls is the number of read or write calls made to the stream.
+	Calls int64
+
+	// Errors is the number of calls that returned an error other than io.EOF.
+	Errors int64
+
+	// Latency is the cumulative time spent in calls to the stream.
+	Latency time.Duration
+}
+
+// Metrics is a snapshot of the counters of an instrumented reader or writer.
+type Metrics struct {
+	// Bytes is the total number of bytes read from or written to all streams.
+	Bytes int64
+
+	// Calls is the total number of read or write calls made to all streams.
+	Calls int64
+
+	// Errors is the total number of calls that returned an error other than
+	// io.EOF.
+	Errors int64
+
+	// Latency is the cumulative time spent in calls to all streams.
+	Latency time.Duration
+
+	// Streams holds the counters for each stream, in the order the streams were
+	// given to the reader or writer's constructor.
+	Streams []StreamMetrics
+}
+
+// meter records the calls made to a reader or writer's streams.
+//
+// A nil meter records nothing, so uninstrumented readers and writers only pay
+// for a nil check.
+type meter struct {
+	mu        sync.Mutex
+	streams   []StreamMetrics
+	observers []Observer
+
+	// names points at the owning reader or writer's stream names, which may be
+	// set after instrumentation is enabled.
+	names *[]string
+}
+
+func newMeter(count int, roleOf func(int) Role, names *[]string, obs []Observer) *meter {
+	out := &meter{
+		streams:   make([]StreamMetrics, count),
+		observers: obs,
+		names:     names,
+	}
+
+	for i := range out.streams {
+		out.streams[i].Role = roleOf(i)
+		out.streams[i].Index = i
+	}
+
+	return out
+}
+
+// observe makes the given call against the stream at the given index,
+// recording it if the meter is not nil.
+func (m *meter) observe(index int, op string, call func() (int, error)) (int, error) {
+	if m == nil {
+		return call()
+	}
+
+	start := time.Now()
+	n, err := call()
+	m.record(index, op, n, err, time.Since(start))
+
+	return n, err
+}
+
+func (m *meter) record(index int, op string, n int, err error, d time.Duration) {
+	m.mu.Lock()
+
+	s := &m.streams[index]
+	s.Bytes += int64(n)
+	s.Calls++
+	s.Latency += d
+
+	if err != nil && err != io.EOF {
+		s.Errors++
+	}
+
+	obs := Observation{
+		Role:    s.Role,
+		Index:   index,
+		Name:    m.name(index),
+		Op:      op,
+		Bytes:   n,
+		Err:     err,
+		Latency: d,
+	}
+
+	m.mu.Unlock()
+
+	for _, o := range m.observers {
+		o.Observe(obs)
+	}
+}
+
+func (m *meter) name(index int) string {
+	if index < len(*m.names) {
+		return (*m.names)[index]
+	}
+
+	return ""
+}
+
+// snapshot returns a copy of the meter's current counters.
+func (m *meter) snapshot() (out Metrics) {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out.Streams = make([]StreamMetrics, len(m.streams))
+
+	for i, s := range m.streams {
+		s.Name = m.name(i)
+		out.Streams[i] = s
+		out.Bytes += s.Bytes
+		out.Calls += s.Calls
+		out.Errors += s.Errors
+		out.Latency += s.Latency
+	}
+
+	return
+}
+
+// inputRole is the role function for multi-reader inputs.
+func inputRole(int) Role {
+	return RoleInput
+}
@@ -0,0 +1,196 @@
+package spipe_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestMultiReader_Metrics(t *testing.T) {
+	Convey("MultiReader.Metrics", t, func() {
+		Convey("not instrumented", func() {
+			test := spipe.NewMultiReader(strings.NewReader("abc"))
+			_, _ = ioutil.ReadAll(test)
+
+			So(test.Metrics(), ShouldResemble, spipe.Metrics{})
+		})
+
+		Convey("per input counters", func() {
+			var seen []spipe.Observation
+
+			test := spipe.NewMultiReader(
+				strings.NewReader("hello"),
+				strings.NewReader("world!"),
+			).
+				Names("a", "b").
+				Instrument(spipe.ObserverFunc(func(o spipe.Observation) {
+					seen = append(seen, o)
+				}))
+
+			out, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "helloworld!")
+
+			m := test.Metrics()
+			So(m.Bytes, ShouldEqual, 11)
+			So(m.Errors, ShouldEqual, 0)
+			So(m.Calls, ShouldEqual, m.Streams[0].Calls+m.Streams[1].Calls)
+			So(len(m.Streams), ShouldEqual, 2)
+
+			So(m.Streams[0].Role, ShouldEqual, spipe.RoleInput)
+			So(m.Streams[0].Index, ShouldEqual, 0)
+			So(m.Streams[0].Name, ShouldEqual, "a")
+			So(m.Streams[0].Bytes, ShouldEqual, 5)
+
+			So(m.Streams[1].Index, ShouldEqual, 1)
+			So(m.Streams[1].Name, ShouldEqual, "b")
+			So(m.Streams[1].Bytes, ShouldEqual, 6)
+
+			So(int64(len(seen)), ShouldEqual, m.Calls)
+			So(seen[0].Op, ShouldEqual, spipe.OpRead)
+			So(seen[0].Name, ShouldEqual, "a")
+			So(seen[0].Bytes, ShouldEqual, 5)
+		})
+
+		Convey("errors", func() {
+			test := spipe.NewMultiReader(
+				&ReadCloser{ReadErrors: []error{errors.New("hiya!")}},
+			).Instrument()
+
+			_, err := test.Read(make([]byte, 4))
+
+			So(err, ShouldNotBeNil)
+			So(test.Metrics().Errors, ShouldEqual, 1)
+			So(test.Metrics().Streams[0].Errors, ShouldEqual, 1)
+		})
+
+		Convey("scanning", func() {
+			test := spipe.NewMultiReader(strings.NewReader("ab")).Instrument()
+
+			_, _ = test.Peek(2)
+			_, _ = test.ReadByte()
+
+			So(test.Metrics().Bytes, ShouldEqual, 2)
+		})
+	})
+}
+
+func TestMultiReadCloser_Metrics(t *testing.T) {
+	Convey("MultiReadCloser.Metrics", t, func() {
+		test := spipe.NewMultiReadCloser(
+			ioutil.NopCloser(strings.NewReader("abc")),
+			ioutil.NopCloser(strings.NewReader("de")),
+		).
+			CloseImmediately(true).
+			Instrument()
+
+		out, err := ioutil.ReadAll(test)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "abcde")
+
+		m := test.Metrics()
+		So(m.Bytes, ShouldEqual, 5)
+		So(m.Streams[0].Bytes, ShouldEqual, 3)
+		So(m.Streams[1].Bytes, ShouldEqual, 2)
+	})
+}
+
+type metricsWriter interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+	Metrics() spipe.Metrics
+}
+
+func tWriterMetrics(construct func(outputs ...io.WriteCloser) metricsWriter) {
+	Convey("per output counters", func() {
+		a, b := new(testWC), new(testWC)
+		test := construct(a, b)
+
+		_, err := test.Write([]byte("hello"))
+		So(err, ShouldBeNil)
+		_, err = test.WriteString("abc")
+		So(err, ShouldBeNil)
+		So(test.WriteByte('!'), ShouldBeNil)
+
+		m := test.Metrics()
+		So(m.Bytes, ShouldEqual, 18)
+		So(m.Calls, ShouldEqual, 6)
+		So(m.Errors, ShouldEqual, 0)
+		So(len(m.Streams), ShouldEqual, 2)
+
+		So(m.Streams[0].Role, ShouldEqual, spipe.RolePrimary)
+		So(m.Streams[0].Name, ShouldEqual, "main")
+		So(m.Streams[0].Bytes, ShouldEqual, 9)
+		So(m.Streams[0].Calls, ShouldEqual, 3)
+
+		So(m.Streams[1].Role, ShouldEqual, spipe.RoleSecondary)
+		So(m.Streams[1].Index, ShouldEqual, 1)
+		So(m.Streams[1].Name, ShouldEqual, "copy")
+		So(m.Streams[1].Bytes, ShouldEqual, 9)
+	})
+
+	Convey("errors", func() {
+		bad := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+		test := construct(new(testWC), bad)
+
+		_, err := test.Write([]byte("hello"))
+		So(err, ShouldNotBeNil)
+
+		m := test.Metrics()
+		So(m.Errors, ShouldEqual, 1)
+		So(m.Streams[0].Errors, ShouldEqual, 0)
+		So(m.Streams[1].Errors, ShouldEqual, 1)
+	})
+}
+
+func TestSplitWriter_Metrics(t *testing.T) {
+	Convey("SplitWriter.Metrics", t, func() {
+		Convey("not instrumented", func() {
+			test := spipe.NewSplitWriter(new(strings.Builder))
+			_, _ = test.Write([]byte("abc"))
+
+			So(test.Metrics(), ShouldResemble, spipe.Metrics{})
+		})
+
+		tWriterMetrics(func(outputs ...io.WriteCloser) metricsWriter {
+			return spipe.NewSplitWriter(outputs[0], outputs[1]).
+				Names("main", "copy").
+				Instrument()
+		})
+
+		Convey("observers", func() {
+			var seen []spipe.Observation
+
+			test := spipe.NewSplitWriter(new(strings.Builder)).
+				Instrument(spipe.ObserverFunc(func(o spipe.Observation) {
+					seen = append(seen, o)
+				}))
+
+			_, _ = test.Write([]byte("abc"))
+
+			So(len(seen), ShouldEqual, 1)
+			So(seen[0].Role, ShouldEqual, spipe.RolePrimary)
+			So(seen[0].Op, ShouldEqual, spipe.OpWrite)
+			So(seen[0].Bytes, ShouldEqual, 3)
+			So(seen[0].Err, ShouldBeNil)
+		})
+	})
+}
+
+func TestSplitWriteCloser_Metrics(t *testing.T) {
+	Convey("SplitWriteCloser.Metrics", t, func() {
+		tWriterMetrics(func(outputs ...io.WriteCloser) metricsWriter {
+			return spipe.NewSplitWriteCloser(outputs[0], outputs[1]).
+				Names("main", "copy").
+				Instrument()
+		})
+	})
+}
@@ -0,0 +1,127 @@
+package spipe
+
+// MultiErrorBuilder collects errors into a MultiError.
+//
+// Nil errors are dropped, and nested MultiError values are flattened so the
+// resulting MultiError only ever contains leaf errors.  A *StreamError wrapping
+// a MultiError is flattened into one *StreamError per nested error, each
+// attributed to the same stream.
+type MultiErrorBuilder interface {
+	// Add appends the given errors to the builder.
+	Add(errs ...error) MultiErrorBuilder
+
+	// Dedupe sets whether errors with the same message as an error that has
+	// already been added should be dropped.  Only affects errors added after it
+	// is set.
+	Dedupe(bool) MultiErrorBuilder
+
+	// Limit sets the maximum number of errors that will be kept.  Errors added
+	// beyond the limit are counted, and reported by the built MultiError's
+	// Omitted method.  A value of 0 disables the limit.
+	Limit(int) MultiErrorBuilder
+
+	// Len returns the number of errors that have been kept.
+	Len() int
+
+	// Build returns a MultiError containing the collected errors, or nil if no
+	// errors were collected.
+	Build() error
+}
+
+// NewMultiErrorBuilder returns a new, empty MultiErrorBuilder instance.
+func NewMultiErrorBuilder() MultiErrorBuilder {
+	return &multiErrorBuilder{}
+}
+
+type multiErrorBuilder struct {
+	errs    []error
+	omitted int
+	limit   int
+	seen    map[string]bool
+}
+
+func (b *multiErrorBuilder) Add(errs ...error) MultiErrorBuilder {
+	for _, e := range errs {
+		b.add(e)
+	}
+
+	return b
+}
+
+func (b *multiErrorBuilder) Dedupe(d bool) MultiErrorBuilder {
+	if !d {
+		b.seen = nil
+		return b
+	}
+
+	if b.seen == nil {
+		b.seen = make(map[string]bool, len(b.errs))
+
+		for _, e := range b.errs {
+			b.seen[e.Error()] = true
+		}
+	}
+
+	return b
+}
+
+func (b *multiErrorBuilder) Limit(n int) MultiErrorBuilder {
+	b.limit = n
+	return b
+}
+
+func (b *multiErrorBuilder) Len() int {
+	return len(b.errs)
+}
+
+func (b *multiErrorBuilder) Build() error {
+	if len(b.errs) == 0 && b.omitted == 0 {
+		return nil
+	}
+
+	return &multiError{errs: b.errs, omitted: b.omitted}
+}
+
+func (b *multiErrorBuilder) add(e error) {
+	switch v := e.(type) {
+	case nil:
+		return
+
+	case MultiError:
+		for _, n := range v.Errors() {
+			b.add(n)
+		}
+
+		b.omitted += v.Omitted()
+		return
+
+	case *StreamError:
+		if m, ok := v.Err.(MultiError); ok {
+			for _, n := range m.Errors() {
+				cp := *v
+				cp.Err = n
+				b.add(&cp)
+			}
+
+			b.omitted += m.Omitted()
+			return
+		}
+	}
+
+	if b.seen != nil {
+		msg := e.Error()
+
+		if b.seen[msg] {
+			return
+		}
+
+		b.seen[msg] = true
+	}
+
+	if b.limit > 0 && len(b.errs) >= b.limit {
+		b.omitted++
+		return
+	}
+
+	b.errs = append(b.errs, e)
+}
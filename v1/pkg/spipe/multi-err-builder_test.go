@@ -0,0 +1,98 @@
+package spipe_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestMultiErrorBuilder(t *testing.T) {
+	Convey("MultiErrorBuilder", t, func() {
+		Convey("empty", func() {
+			So(spipe.NewMultiErrorBuilder().Build(), ShouldBeNil)
+			So(spipe.NewMultiErrorBuilder().Add(nil, nil).Build(), ShouldBeNil)
+		})
+
+		Convey("flattens nested errors", func() {
+			a, b, c := errors.New("a"), errors.New("b"), errors.New("c")
+
+			err := spipe.NewMultiErrorBuilder().
+				Add(a, nil, spipe.NewMultiError([]error{b, spipe.NewMultiError([]error{c})})).
+				Build()
+
+			So(err.(spipe.MultiError).Errors(), ShouldResemble, []error{a, b, c})
+		})
+
+		Convey("flattens nested errors inside a StreamError", func() {
+			inner := spipe.NewMultiError([]error{errors.New("a"), errors.New("b")})
+
+			err := spipe.NewMultiErrorBuilder().
+				Add(&spipe.StreamError{Role: spipe.RoleInput, Index: 3, Op: spipe.OpClose, Err: inner}).
+				Build()
+
+			So(err.(spipe.MultiError).Errors(), ShouldResemble, []error{
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 3, Op: spipe.OpClose, Err: errors.New("a")},
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 3, Op: spipe.OpClose, Err: errors.New("b")},
+			})
+		})
+
+		Convey("dedupe", func() {
+			err := spipe.NewMultiErrorBuilder().
+				Dedupe(true).
+				Add(errors.New("a"), errors.New("b"), errors.New("a")).
+				Build()
+
+			So(err.Error(), ShouldEqual, "a\nb")
+		})
+
+		Convey("limit", func() {
+			b := spipe.NewMultiErrorBuilder().Limit(2)
+
+			for i := 0; i < 5; i++ {
+				b.Add(fmt.Errorf("%d", i))
+			}
+
+			So(b.Len(), ShouldEqual, 2)
+
+			err := b.Build().(spipe.MultiError)
+
+			So(err.Omitted(), ShouldEqual, 3)
+			So(err.Error(), ShouldEqual, "0\n1\n(3 more omitted)")
+			So(fmt.Sprintf("%v", err), ShouldEqual, "5 errors occurred: 0; 1; (3 more omitted)")
+			So(fmt.Sprintf("%+v", err), ShouldEqual, "5 errors occurred:\n\t* 0\n\t* 1\n\t* (3 more omitted)")
+
+			Convey("carries omitted counts through flattening", func() {
+				outer := spipe.NewMultiErrorBuilder().Add(errors.New("x"), err).Build()
+
+				So(outer.(spipe.MultiError).Omitted(), ShouldEqual, 3)
+				So(outer.(spipe.MultiError).Errors(), ShouldHaveLength, 3)
+			})
+		})
+
+		Convey("through Close", func() {
+			nested := func() error {
+				return spipe.NewSplitWriteCloser(
+					&testWC{cl: func() error { return errors.New("hi") }},
+				).Close()
+			}
+
+			err := spipe.NewMultiReadCloser(testRc{cl: nested}).Close()
+
+			So(err.(spipe.MultiError).Errors(), ShouldResemble, []error{
+				&spipe.StreamError{
+					Role: spipe.RoleInput,
+					Op:   spipe.OpClose,
+					Err: &spipe.StreamError{
+						Role: spipe.RolePrimary,
+						Op:   spipe.OpClose,
+						Err:  errors.New("hi"),
+					},
+				},
+			})
+		})
+	})
+}
@@ -0,0 +1,29 @@
+//go:build go1.21
+// +build go1.21
+
+package spipe
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer.
+//
+// The error renders as a group holding the number of wrapped errors and their
+// messages, along with the number of omitted errors if there were any.
+func (m *multiError) LogValue() slog.Value {
+	msgs := make([]string, len(m.errs))
+
+	for i, e := range m.errs {
+		msgs[i] = e.Error()
+	}
+
+	attrs := []slog.Attr{
+		slog.Int("count", len(m.errs)+m.omitted),
+		slog.Any("errors", msgs),
+	}
+
+	if m.omitted > 0 {
+		attrs = append(attrs, slog.Int("omitted", m.omitted))
+	}
+
+	return slog.GroupValue(attrs...)
+}
@@ -0,0 +1,35 @@
+//go:build go1.21
+// +build go1.21
+
+package spipe_test
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestMultiError_LogValue(t *testing.T) {
+	Convey("MultiError.LogValue", t, func() {
+		buf := new(bytes.Buffer)
+		log := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{
+			ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey {
+					return slog.Attr{}
+				}
+				return a
+			},
+		}))
+
+		err := spipe.NewMultiError([]error{errors.New("a"), errors.New("b")})
+		log.Error("failed", "err", err)
+
+		So(buf.String(), ShouldEqual, `{"level":"ERROR","msg":"failed",`+
+			`"err":{"count":2,"errors":["a","b"]}}`+"\n")
+	})
+}
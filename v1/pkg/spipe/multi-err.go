@@ -1,23 +1,67 @@
 package spipe
 
-import "strings"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
 
 // MultiError wraps a slice of errors into a single error type.
+//
+// The MultiError values returned by this package implement `Unwrap() []error`,
+// so `errors.Is` and `errors.As` match against any of the wrapped errors.  The
+// equivalent Is and As methods are also provided for toolchains older than Go
+// 1.20, which do not understand multi-error unwrapping.
+//
+// When formatted with the `%v` verb a MultiError renders as a single line
+// summary, while the `%+v` verb renders an indented list with one error per
+// line.  The `%s` verb renders the same value as Error.
+//
+// A MultiError marshals to JSON as an array of objects, one per wrapped error.
+// On Go 1.21 and newer a MultiError also implements `slog.LogValuer`.
 type MultiError interface {
 	error
+	fmt.Formatter
+	json.Marshaler
 
 	// Errors returns the original errors backing this error.
 	Errors() []error
+
+	// Omitted returns the number of errors that were dropped from this error
+	// due to a MultiErrorBuilder limit.
+	Omitted() int
 }
 
 // NewMultiError constructs a new MultiError instance from the given slice of
 // errors.
+//
+// Nil entries in the given slice are dropped.  If the given slice is empty or
+// contains only nil entries, NewMultiError returns nil.
+//
+// Use MultiErrorBuilder to flatten nested errors, deduplicate, or limit the
+// number of errors kept.
 func NewMultiError(errs []error) MultiError {
-	return &multiError{errs}
+	var out []error
+
+	for _, e := range errs {
+		if e != nil {
+			out = append(out, e)
+		}
+	}
+
+	if len(out) == 0 {
+		return nil
+	}
+
+	return &multiError{errs: out}
 }
 
 type multiError struct {
-	errs []error
+	errs    []error
+	omitted int
 }
 
 func (m *multiError) Error() string {
@@ -31,9 +75,154 @@ func (m *multiError) Error() string {
 		out.WriteString(e.Error())
 	}
 
+	if m.omitted > 0 {
+		if len(m.errs) > 0 {
+			out.WriteByte('\n')
+		}
+
+		out.WriteString(m.omittedText())
+	}
+
 	return out.String()
 }
 
 func (m *multiError) Errors() []error {
 	return m.errs
 }
+
+func (m *multiError) Omitted() int {
+	return m.omitted
+}
+
+// Unwrap returns the original errors backing this error.
+func (m *multiError) Unwrap() []error {
+	return m.errs
+}
+
+// Is reports whether any of the wrapped errors matches the given target, as
+// defined by errors.Is.
+func (m *multiError) Is(target error) bool {
+	for _, e := range m.errs {
+		if errors.Is(e, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first of the wrapped errors that matches the given target, as
+// defined by errors.As.
+func (m *multiError) As(target interface{}) bool {
+	for _, e := range m.errs {
+		if errors.As(e, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Format implements fmt.Formatter.
+func (m *multiError) Format(f fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if f.Flag('+') {
+			m.formatList(f)
+		} else {
+			m.formatLine(f)
+		}
+	case 's':
+		io.WriteString(f, m.Error())
+	case 'q':
+		io.WriteString(f, strconv.Quote(m.Error()))
+	default:
+		fmt.Fprintf(f, "%%!%c(%s)", verb, m.summary())
+	}
+}
+
+// formatLine writes a one line summary such as "2 errors occurred: a; b".
+func (m *multiError) formatLine(w io.Writer) {
+	io.WriteString(w, m.summary())
+	io.WriteString(w, ": ")
+
+	for i, e := range m.errs {
+		if i > 0 {
+			io.WriteString(w, "; ")
+		}
+
+		fmt.Fprintf(w, "%v", e)
+	}
+
+	if m.omitted > 0 {
+		if len(m.errs) > 0 {
+			io.WriteString(w, "; ")
+		}
+
+		io.WriteString(w, m.omittedText())
+	}
+}
+
+// formatList writes the summary followed by an indented line per error.
+func (m *multiError) formatList(w io.Writer) {
+	io.WriteString(w, m.summary())
+	io.WriteString(w, ":")
+
+	for _, e := range m.errs {
+		line := fmt.Sprintf("%+v", e)
+		io.WriteString(w, "\n\t* ")
+		io.WriteString(w, strings.ReplaceAll(line, "\n", "\n\t  "))
+	}
+
+	if m.omitted > 0 {
+		io.WriteString(w, "\n\t* ")
+		io.WriteString(w, m.omittedText())
+	}
+}
+
+func (m *multiError) summary() string {
+	if total := len(m.errs) + m.omitted; total != 1 {
+		return strconv.Itoa(total) + " errors occurred"
+	}
+
+	return "1 error occurred"
+}
+
+func (m *multiError) omittedText() string {
+	return "(" + strconv.Itoa(m.omitted) + " more omitted)"
+}
+
+// jsonError is the JSON representation of a single error wrapped by a
+// MultiError.
+type jsonError struct {
+	Error string `json:"error"`
+	Role  string `json:"role,omitempty"`
+	Index *int   `json:"index,omitempty"`
+	Name  string `json:"name,omitempty"`
+	Op    string `json:"op,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+//
+// Each wrapped error is encoded as an object with an "error" field holding its
+// message.  Errors wrapping a *StreamError also carry the "role", "index",
+// "name" and "op" fields identifying the failing stream.
+func (m *multiError) MarshalJSON() ([]byte, error) {
+	out := make([]jsonError, len(m.errs))
+
+	for i, e := range m.errs {
+		out[i].Error = e.Error()
+
+		var se *StreamError
+		if errors.As(e, &se) {
+			index := se.Index
+
+			out[i].Role = se.Role.String()
+			out[i].Index = &index
+			out[i].Name = se.Name
+			out[i].Op = se.Op
+		}
+	}
+
+	return json.Marshal(out)
+}
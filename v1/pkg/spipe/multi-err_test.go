@@ -0,0 +1,135 @@
+package spipe_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestNewMultiError(t *testing.T) {
+	Convey("NewMultiError", t, func() {
+		Convey("empty", func() {
+			So(spipe.NewMultiError(nil), ShouldBeNil)
+			So(spipe.NewMultiError([]error{}), ShouldBeNil)
+		})
+
+		Convey("all nil", func() {
+			var err error = spipe.NewMultiError([]error{nil, nil})
+
+			So(err == nil, ShouldBeTrue)
+		})
+
+		Convey("some nil", func() {
+			err := spipe.NewMultiError([]error{nil, io.EOF, nil})
+
+			So(err.Errors(), ShouldResemble, []error{io.EOF})
+			So(err.Error(), ShouldEqual, "EOF")
+		})
+	})
+}
+
+func TestMultiError_Is(t *testing.T) {
+	Convey("MultiError.Is", t, func() {
+		err := spipe.NewMultiError([]error{
+			errors.New("a"),
+			&os.PathError{Op: "close", Path: "b", Err: os.ErrClosed},
+		})
+
+		So(errors.Is(err, os.ErrClosed), ShouldBeTrue)
+		So(errors.Is(err, io.EOF), ShouldBeFalse)
+
+		Convey("through Close", func() {
+			fn := func() error { return &os.PathError{Op: "close", Err: os.ErrClosed} }
+
+			err := spipe.NewSplitWriteCloser(&testWC{cl: fn}).Close()
+			So(errors.Is(err, os.ErrClosed), ShouldBeTrue)
+
+			err = spipe.NewMultiReadCloser(testRc{cl: fn}).Close()
+			So(errors.Is(err, os.ErrClosed), ShouldBeTrue)
+		})
+	})
+}
+
+func TestMultiError_As(t *testing.T) {
+	Convey("MultiError.As", t, func() {
+		err := spipe.NewMultiError([]error{
+			errors.New("a"),
+			&os.PathError{Op: "close", Path: "b", Err: os.ErrClosed},
+		})
+
+		var target *os.PathError
+
+		So(errors.As(err, &target), ShouldBeTrue)
+		So(target.Path, ShouldEqual, "b")
+
+		var missing *spipe.ChunkError
+		So(errors.As(err, &missing), ShouldBeFalse)
+	})
+}
+
+func TestMultiError_Unwrap(t *testing.T) {
+	Convey("MultiError.Unwrap", t, func() {
+		errs := []error{errors.New("a"), errors.New("b")}
+
+		unwrapper, ok := spipe.NewMultiError(errs).(interface{ Unwrap() []error })
+		So(ok, ShouldBeTrue)
+		So(unwrapper.Unwrap(), ShouldResemble, errs)
+	})
+}
+
+func TestMultiError_Format(t *testing.T) {
+	Convey("MultiError.Format", t, func() {
+		err := spipe.NewMultiError([]error{
+			errors.New("a"),
+			spipe.NewMultiError([]error{errors.New("b"), errors.New("c")}),
+		})
+
+		Convey("%v", func() {
+			So(fmt.Sprintf("%v", err), ShouldEqual,
+				"2 errors occurred: a; 2 errors occurred: b; c")
+			So(fmt.Sprintf("%v", spipe.NewMultiError([]error{io.EOF})), ShouldEqual,
+				"1 error occurred: EOF")
+		})
+
+		Convey("%+v", func() {
+			So(fmt.Sprintf("%+v", err), ShouldEqual, "2 errors occurred:\n"+
+				"\t* a\n"+
+				"\t* 2 errors occurred:\n"+
+				"\t  \t* b\n"+
+				"\t  \t* c")
+		})
+
+		Convey("%s", func() {
+			So(fmt.Sprintf("%s", err), ShouldEqual, "a\nb\nc")
+			So(fmt.Sprintf("%q", err), ShouldEqual, `"a\nb\nc"`)
+		})
+	})
+}
+
+func TestMultiError_MarshalJSON(t *testing.T) {
+	Convey("MultiError.MarshalJSON", t, func() {
+		err := spipe.NewMultiError([]error{
+			errors.New("a"),
+			&spipe.StreamError{
+				Role:  spipe.RolePrimary,
+				Index: 0,
+				Name:  "main",
+				Op:    spipe.OpClose,
+				Err:   errors.New("b"),
+			},
+		})
+
+		out, e := json.Marshal(err)
+
+		So(e, ShouldBeNil)
+		So(string(out), ShouldEqual, `[{"error":"a"},`+
+			`{"error":"Close primary 0 (main): b","role":"primary","index":0,"name":"main","op":"Close"}]`)
+	})
+}
@@ -0,0 +1,918 @@
+package spipe
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"sync"
+)
+
+// Each frame is a flag byte, the stream id and payload length as unsigned
+// varints, and the payload.  Data frames carry stream bytes, close frames have
+// no payload and error frames carry the error text.  Credit frames are sent
+// back from a Demux to a Mux and carry the number of bytes granted to the
+// stream as an unsigned varint.
+const (
+	frameData byte = iota
+	frameClose
+	frameError
+	frameCredit
+)
+
+const (
+	// DefaultFrameSize is the default largest payload a Mux puts in a frame.
+	DefaultFrameSize = 16 * 1024
+
+	// MaxFrameSize is the largest frame payload a Mux will write and a Demux
+	// will accept.
+	MaxFrameSize = 1024 * 1024
+
+	// DefaultMuxWindow is the default number of bytes each stream may have in
+	// flight when flow control is enabled.
+	DefaultMuxWindow = 256 * 1024
+)
+
+// ErrBadFrame is returned by the streams of a Demux when the input contains a
+// frame that is malformed or names a stream that does not exist.
+var ErrBadFrame = errors.New("spipe: malformed mux frame")
+
+// RemoteError is returned by a stream of a Demux when the sending stream was
+// closed with an error.
+type RemoteError struct {
+	// Message is the text of the error the sending stream was closed with.
+	Message string
+}
+
+func (r *RemoteError) Error() string {
+	return "spipe: stream closed with error: " + r.Message
+}
+
+// MuxStream is one of the logical streams of a Mux.
+type MuxStream interface {
+	io.WriteCloser
+
+	// CloseWithError closes the stream, causing reads of the matching Demux
+	// stream to return a RemoteError with the given error's text once the data
+	// written before it has been read.
+	CloseWithError(error) error
+}
+
+// Mux defines a multiplexer that carries several logical streams over one
+// underlying writer, such as a network connection, to be separated again by a
+// Demux.
+//
+// Writes to each stream are cut into frames and queued.  A single goroutine
+// writes the queued frames to the underlying writer, taking one frame from
+// each stream in turn, so a busy stream cannot starve the others.  Once a
+// stream has QueueSize frames queued, writes to it block until the queue
+// drains.
+//
+// Each stream may be written to from a different goroutine, but a single
+// stream must not be written to concurrently.
+type Mux interface {
+	// Stream returns the stream at the given index, which must be less than the
+	// number of streams the Mux was constructed with.
+	Stream(index int) MuxStream
+
+	// FrameSize sets the largest payload put in a single frame.  Has no effect
+	// once the first write has been made.  Defaults to DefaultFrameSize, and is
+	// capped at MaxFrameSize.
+	FrameSize(int) Mux
+
+	// QueueSize sets the number of frames each stream may have queued before
+	// writes to it block.  Has no effect once the first write has been made.
+	// Defaults to DefaultQueueSize.
+	QueueSize(int) Mux
+
+	// FlowControl enables per-stream flow control, reading the window updates
+	// sent by the matching Demux from the given reader.  Each stream may then
+	// have about window bytes sent that the Demux stream has not yet read.
+	// Once it has, the stream's frames are held back until the Demux grants
+	// more, while the other streams carry on.  The Demux must be given the
+	// same window.  A window below 1 uses DefaultMuxWindow.  Has no effect
+	// once the first write has been made.
+	//
+	// The window updates are read by a goroutine that stops when the reader
+	// ends or fails.  If it does while frames are being held back, the Mux
+	// fails with the reader's error.
+	FlowControl(credits io.Reader, window int) Mux
+
+	// Close closes every stream that is still open and waits for every queued
+	// frame to be written.  The underlying writer is not closed.  Close must
+	// not be called while streams are being written to.
+	//
+	// Returns the error, if any, that the underlying writer failed with.
+	Close() error
+}
+
+// NewMux returns a new Mux instance carrying the given number of streams over
+// the given writer.
+//
+// If the underlying writer fails, the error is returned from every later call
+// to Write or Close on any stream, and anything still queued is discarded.
+func NewMux(out io.Writer, streams int) Mux {
+	m := &mux{
+		out:       out,
+		frameSize: DefaultFrameSize,
+		queueSize: DefaultQueueSize,
+		streams:   make([]*muxStream, streams),
+		queues:    make([][]muxFrame, streams),
+	}
+
+	m.cond = sync.NewCond(&m.mu)
+
+	for i := range m.streams {
+		m.streams[i] = &muxStream{mux: m, id: i}
+	}
+
+	return m
+}
+
+type mux struct {
+	out       io.Writer
+	frameSize int
+	queueSize int
+	streams   []*muxStream
+	creditIn  io.Reader
+
+	// mu guards everything below, cond is signalled whenever a frame is
+	// queued or written, credit is granted, or the mux fails or is closed.
+	mu      sync.Mutex
+	cond    *sync.Cond
+	queues  [][]muxFrame
+	next    int
+	started bool
+	closed  bool
+	err     error
+	worker  sync.WaitGroup
+
+	// credits holds the bytes each stream may still send, nil unless flow
+	// control is enabled.  creditErr is the error reading credit ended with.
+	credits   []int64
+	creditErr error
+}
+
+// muxFrame is an encoded frame and the size of its payload counted against
+// the stream's window.
+type muxFrame struct {
+	data []byte
+	size int
+}
+
+func (m *mux) Stream(index int) MuxStream {
+	return m.streams[index]
+}
+
+func (m *mux) FrameSize(n int) Mux {
+	if n < 1 {
+		n = 1
+	} else if n > MaxFrameSize {
+		n = MaxFrameSize
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.started {
+		m.frameSize = n
+	}
+
+	return m
+}
+
+func (m *mux) QueueSize(n int) Mux {
+	if n < 1 {
+		n = 1
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.started {
+		m.queueSize = n
+	}
+
+	return m
+}
+
+func (m *mux) FlowControl(credits io.Reader, window int) Mux {
+	if window < 1 {
+		window = DefaultMuxWindow
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.started {
+		m.creditIn = credits
+		m.credits = make([]int64, len(m.streams))
+
+		for i := range m.credits {
+			m.credits[i] = int64(window)
+		}
+	}
+
+	return m
+}
+
+func (m *mux) Close() error {
+	for _, s := range m.streams {
+		_ = s.Close()
+	}
+
+	m.mu.Lock()
+	m.closed = true
+	m.cond.Broadcast()
+	m.mu.Unlock()
+
+	m.worker.Wait()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.err
+}
+
+// queue adds a frame for the given stream, waiting for room in the stream's
+// queue unless force is set.
+func (m *mux) queue(id int, flag byte, payload []byte, force bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.started {
+		m.started = true
+		m.worker.Add(1)
+
+		go m.work()
+
+		if m.creditIn != nil {
+			go m.readCredits()
+		}
+	}
+
+	for !force && m.err == nil && len(m.queues[id]) >= m.queueSize {
+		m.cond.Wait()
+	}
+
+	if m.err != nil {
+		return m.err
+	}
+
+	frame := muxFrame{data: encodeFrame(flag, id, payload)}
+	if flag == frameData {
+		frame.size = len(payload)
+	}
+
+	m.queues[id] = append(m.queues[id], frame)
+	m.cond.Broadcast()
+
+	return nil
+}
+
+func (m *mux) work() {
+	defer m.worker.Done()
+
+	for {
+		frame, ok := m.take()
+		if !ok {
+			return
+		}
+
+		if err := writeRecordTo(m.out, frame); err != nil {
+			m.mu.Lock()
+			m.fail(err)
+			m.mu.Unlock()
+		}
+	}
+}
+
+// fail records the error the mux failed with and discards everything queued.
+// Must be called with mu held.
+func (m *mux) fail(err error) {
+	m.err = err
+
+	for i := range m.queues {
+		m.queues[i] = nil
+	}
+
+	m.cond.Broadcast()
+}
+
+// take waits for a frame that may be sent and removes it, taking from the
+// streams in turn and skipping those that have used up their window.  Returns
+// false once the mux has been closed and every queue has drained.
+func (m *mux) take() ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for {
+		held := false
+
+		for i := range m.queues {
+			id := (m.next + i) % len(m.queues)
+
+			if len(m.queues[id]) == 0 {
+				continue
+			}
+
+			frame := m.queues[id][0]
+
+			if m.credits != nil && frame.size > 0 {
+				if m.credits[id] <= 0 {
+					held = true
+					continue
+				}
+
+				m.credits[id] -= int64(frame.size)
+			}
+
+			m.queues[id] = m.queues[id][1:]
+			m.next = id + 1
+			m.cond.Broadcast()
+
+			return frame.data, true
+		}
+
+		if held && m.creditErr != nil {
+			m.fail(m.creditErr)
+			continue
+		}
+
+		if m.closed && !held {
+			return nil, false
+		}
+
+		m.cond.Wait()
+	}
+}
+
+// readCredits reads the window updates sent by the Demux until the reader
+// ends or fails.
+func (m *mux) readCredits() {
+	in := bufio.NewReader(m.creditIn)
+
+	for {
+		flag, id, payload, err := readFrame(in, len(m.streams))
+
+		var grant uint64
+		if err == nil {
+			grant, err = decodeCredit(flag, payload)
+		}
+
+		m.mu.Lock()
+
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			m.creditErr = err
+			m.cond.Broadcast()
+			m.mu.Unlock()
+
+			return
+		}
+
+		m.credits[id] += int64(grant)
+		m.cond.Broadcast()
+		m.mu.Unlock()
+	}
+}
+
+// decodeCredit returns the bytes granted by a credit frame.
+func decodeCredit(flag byte, payload []byte) (uint64, error) {
+	grant, n := binary.Uvarint(payload)
+
+	if flag != frameCredit || n <= 0 || n != len(payload) || grant > math.MaxInt32 {
+		return 0, ErrBadFrame
+	}
+
+	return grant, nil
+}
+
+func encodeCredit(id int, grant int64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return encodeFrame(frameCredit, id, buf[:binary.PutUvarint(buf[:], uint64(grant))])
+}
+
+func encodeFrame(flag byte, id int, payload []byte) []byte {
+	frame := make([]byte, 1, 1+2*binary.MaxVarintLen64+len(payload))
+	frame[0] = flag
+
+	var buf [binary.MaxVarintLen64]byte
+	frame = append(frame, buf[:binary.PutUvarint(buf[:], uint64(id))]...)
+	frame = append(frame, buf[:binary.PutUvarint(buf[:], uint64(len(payload)))]...)
+
+	return append(frame, payload...)
+}
+
+type muxStream struct {
+	mux    *mux
+	id     int
+	closed bool
+}
+
+// Write cuts the given bytes into frames and queues them to be written.
+//
+// The returned byte count is len(p) unless the underlying writer has failed,
+// in which case the count of bytes queued before the failure was seen is
+// returned with the error.
+func (s *muxStream) Write(p []byte) (n int, err error) {
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+
+	for len(p) > 0 {
+		k := s.mux.frameLimit()
+		if k > len(p) {
+			k = len(p)
+		}
+
+		if err = s.mux.queue(s.id, frameData, p[:k], false); err != nil {
+			return
+		}
+
+		p = p[k:]
+		n += k
+	}
+
+	return
+}
+
+// Close queues a close frame for the stream.  It does not wait for the frame
+// to be written, Close on the Mux does.
+func (s *muxStream) Close() error {
+	return s.close(frameClose, nil)
+}
+
+func (s *muxStream) CloseWithError(err error) error {
+	msg := []byte(err.Error())
+
+	if len(msg) > MaxFrameSize {
+		msg = msg[:MaxFrameSize]
+	}
+
+	return s.close(frameError, msg)
+}
+
+func (s *muxStream) close(flag byte, payload []byte) error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	return s.mux.queue(s.id, flag, payload, true)
+}
+
+func (m *mux) frameLimit() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.frameSize
+}
+
+// Demux defines a demultiplexer that separates the streams carried by a Mux
+// over a single reader.
+//
+// A goroutine reads frames from the underlying reader and hands each to its
+// stream, which holds up to QueueSize frames until they are read.  Without
+// flow control, a stream that is not being read stalls every other stream once
+// its queue is full, so streams that are not wanted should be closed, after
+// which their frames are discarded.
+type Demux interface {
+	// Stream returns the stream at the given index, which must be less than the
+	// number of streams the Demux was constructed with.
+	Stream(index int) io.ReadCloser
+
+	// QueueSize sets the number of frames each stream may hold before reading
+	// from the underlying reader blocks.  Has no effect once the first read has
+	// been made.  Defaults to DefaultQueueSize.
+	QueueSize(int) Demux
+
+	// FlowControl enables per-stream flow control, writing window updates for
+	// the matching Mux to the given writer as the streams are read.  The Mux
+	// must be given the same window.  A window below 1 uses DefaultMuxWindow.
+	// Has no effect once the first read has been made.
+	//
+	// As the Mux sends no more than each stream's window, frames are then held
+	// for each stream without limit and QueueSize is ignored, so a stream that
+	// is not being read no longer stalls the others.  If writing a window
+	// update fails, every stream ends with the error once its held frames
+	// have been read.
+	FlowControl(credits io.Writer, window int) Demux
+
+	// Close closes every stream and, if the underlying reader is an io.Closer,
+	// closes it.  The reading goroutine stops once any read of the underlying
+	// reader already in progress returns.
+	Close() error
+}
+
+// NewDemux returns a new Demux instance separating the given number of streams
+// from the given reader.
+//
+// The reader is read until every stream has been closed by the sending side,
+// the reader returns an error, or the Demux is closed.  If the reader ends or
+// fails before a stream was closed by the sending side, reads of that stream
+// return io.ErrUnexpectedEOF or the reader's error once its held frames have
+// been read.
+func NewDemux(in io.Reader, streams int) Demux {
+	d := &demux{
+		in:        in,
+		queueSize: DefaultQueueSize,
+		streams:   make([]*demuxStream, streams),
+		done:      make(chan struct{}),
+	}
+
+	d.cond = sync.NewCond(&d.mu)
+
+	for i := range d.streams {
+		d.streams[i] = &demuxStream{demux: d, id: i}
+	}
+
+	return d
+}
+
+type demux struct {
+	in        io.Reader
+	queueSize int
+	streams   []*demuxStream
+
+	// done is closed by Close to stop the reading goroutine.
+	done chan struct{}
+
+	// creditMu serialises writes of window updates, which are made without
+	// holding mu.
+	creditMu  sync.Mutex
+	creditOut io.Writer
+	window    int
+
+	// mu guards the demux and its streams, cond is signalled whenever a frame
+	// is delivered or read, or a stream ends or is closed.
+	mu      sync.Mutex
+	cond    *sync.Cond
+	started bool
+	closed  bool
+}
+
+type demuxStream struct {
+	demux *demux
+	id    int
+
+	// frames holds the payloads delivered but not yet read.
+	frames [][]byte
+
+	// ended is set once no more frames will be delivered, err is then returned
+	// once frames are drained.
+	ended  bool
+	err    error
+	closed bool
+
+	// grant counts the bytes read or discarded that have not yet been granted
+	// back to the Mux.
+	grant int64
+}
+
+func (d *demux) Stream(index int) io.ReadCloser {
+	return d.streams[index]
+}
+
+func (d *demux) QueueSize(n int) Demux {
+	if n < 1 {
+		n = 1
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.started {
+		d.queueSize = n
+	}
+
+	return d
+}
+
+func (d *demux) FlowControl(credits io.Writer, window int) Demux {
+	if window < 1 {
+		window = DefaultMuxWindow
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if !d.started {
+		d.creditOut = credits
+		d.window = window
+	}
+
+	return d
+}
+
+func (d *demux) Close() error {
+	d.mu.Lock()
+
+	for _, s := range d.streams {
+		s.shut()
+	}
+
+	if !d.closed {
+		d.closed = true
+		close(d.done)
+	}
+
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	if c, ok := d.in.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// start starts the reading goroutine if it is not already running.  Must be
+// called with mu held.
+func (d *demux) start() {
+	if d.started {
+		return
+	}
+
+	d.started = true
+
+	go d.work()
+}
+
+func (d *demux) work() {
+	in := bufio.NewReader(d.in)
+
+	for d.open() {
+		flag, id, payload, err := readFrame(in, len(d.streams))
+
+		if err == nil && flag == frameCredit {
+			err = ErrBadFrame
+		}
+
+		select {
+		case <-d.done:
+			return
+		default:
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			d.endAll(err)
+			return
+		}
+
+		d.deliver(d.streams[id], flag, payload)
+		d.sendCredits()
+	}
+}
+
+// open reports whether any stream may still receive frames.
+func (d *demux) open() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.closed {
+		return false
+	}
+
+	for _, s := range d.streams {
+		if !s.ended {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (d *demux) deliver(s *demuxStream, flag byte, payload []byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s.ended {
+		return
+	}
+
+	switch flag {
+	case frameClose:
+		s.end(io.EOF)
+	case frameError:
+		s.end(&RemoteError{Message: string(payload)})
+	default:
+		for d.creditOut == nil && !s.closed && !d.closed && len(s.frames) >= d.queueSize {
+			d.cond.Wait()
+		}
+
+		if s.closed {
+			s.grant += int64(len(payload))
+		} else if len(payload) > 0 {
+			s.frames = append(s.frames, payload)
+		}
+	}
+
+	d.cond.Broadcast()
+}
+
+func (d *demux) endAll(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, s := range d.streams {
+		if !s.ended {
+			s.end(err)
+		}
+	}
+
+	d.cond.Broadcast()
+}
+
+// sendCredits writes window updates to the Mux for the streams that have read
+// or discarded at least half their window, and for closed streams that have
+// anything to grant.
+func (d *demux) sendCredits() {
+	d.creditMu.Lock()
+	defer d.creditMu.Unlock()
+
+	d.mu.Lock()
+
+	if d.creditOut == nil || d.closed {
+		d.mu.Unlock()
+		return
+	}
+
+	threshold := int64(d.window / 2)
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	var updates []byte
+
+	for _, s := range d.streams {
+		if !s.ended && (s.grant >= threshold || (s.closed && s.grant > 0)) {
+			updates = append(updates, encodeCredit(s.id, s.grant)...)
+			s.grant = 0
+		}
+	}
+
+	d.mu.Unlock()
+
+	if len(updates) == 0 {
+		return
+	}
+
+	if err := writeRecordTo(d.creditOut, updates); err != nil {
+		d.endAll(err)
+	}
+}
+
+func readFrame(in *bufio.Reader, streams int) (flag byte, id int, payload []byte, err error) {
+	if flag, err = in.ReadByte(); err != nil {
+		return
+	}
+
+	if flag > frameCredit {
+		err = ErrBadFrame
+		return
+	}
+
+	rawID, err := readUvarint(in)
+	if err != nil {
+		return
+	}
+
+	size, err := readUvarint(in)
+	if err != nil {
+		return
+	}
+
+	if rawID >= uint64(streams) || size > MaxFrameSize {
+		err = ErrBadFrame
+		return
+	}
+
+	payload = make([]byte, size)
+	if _, err = io.ReadFull(in, payload); err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return flag, int(rawID), payload, err
+}
+
+// readUvarint reads a varint within a frame, reporting an input that ends part
+// way through the frame as an unexpected EOF and a varint that overflows as a
+// malformed frame.
+func readUvarint(in io.ByteReader) (uint64, error) {
+	var x uint64
+
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := in.ReadByte()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+
+			return 0, err
+		}
+
+		if b < 0x80 {
+			return x | uint64(b)<<shift, nil
+		}
+
+		x |= uint64(b&0x7f) << shift
+	}
+
+	return 0, ErrBadFrame
+}
+
+// Read returns the stream's data in the order it was written to the matching
+// Mux stream, blocking until data arrives or the stream ends.
+func (s *demuxStream) Read(p []byte) (int, error) {
+	n, err := s.read(p)
+	s.demux.sendCredits()
+
+	return n, err
+}
+
+func (s *demuxStream) read(p []byte) (int, error) {
+	d := s.demux
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if s.closed {
+		return 0, os.ErrClosed
+	}
+
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	d.start()
+
+	for len(s.frames) == 0 && !s.ended {
+		d.cond.Wait()
+
+		if s.closed {
+			return 0, os.ErrClosed
+		}
+	}
+
+	n := 0
+	for len(s.frames) > 0 && n < len(p) {
+		k := copy(p[n:], s.frames[0])
+		n += k
+
+		if k == len(s.frames[0]) {
+			s.frames = s.frames[1:]
+		} else {
+			s.frames[0] = s.frames[0][k:]
+		}
+	}
+
+	s.grant += int64(n)
+	d.cond.Broadcast()
+
+	if n > 0 {
+		return n, nil
+	}
+
+	return 0, s.err
+}
+
+// Close discards the stream's held frames and any that arrive later.  With
+// flow control, discarded frames are granted back to the Mux so the matching
+// Mux stream is not held back.
+func (s *demuxStream) Close() error {
+	s.demux.mu.Lock()
+	s.shut()
+	s.demux.cond.Broadcast()
+	s.demux.mu.Unlock()
+
+	s.demux.sendCredits()
+
+	return nil
+}
+
+// shut closes the stream.  Must be called with mu held.
+func (s *demuxStream) shut() {
+	for _, f := range s.frames {
+		s.grant += int64(len(f))
+	}
+
+	s.closed = true
+	s.frames = nil
+}
+
+// end marks that no more frames will be delivered.  Must be called with mu
+// held.
+func (s *demuxStream) end(err error) {
+	s.ended = true
+	s.err = err
+}
@@ -0,0 +1,337 @@
+package spipe_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// frameLog records the stream id of every frame written to it, assuming one
+// frame per write and single byte varints.  Writes wait for gate to be closed.
+type frameLog struct {
+	bytes.Buffer
+	ids  []byte
+	gate chan struct{}
+}
+
+func (f *frameLog) Write(p []byte) (int, error) {
+	<-f.gate
+	f.ids = append(f.ids, p[1])
+	return f.Buffer.Write(p)
+}
+
+func TestMux(t *testing.T) {
+	Convey("Mux and Demux", t, func() {
+		wire := new(bytes.Buffer)
+		mux := spipe.NewMux(wire, 3).FrameSize(4)
+
+		inputs := []string{
+			"the quick brown fox",
+			"",
+			strings.Repeat("jumps over the lazy dog ", 50),
+		}
+
+		Convey("round trip", func() {
+			var wg sync.WaitGroup
+			for i, in := range inputs {
+				wg.Add(1)
+				go func(i int, in string) {
+					defer wg.Done()
+					_, _ = mux.Stream(i).Write([]byte(in))
+				}(i, in)
+			}
+			wg.Wait()
+
+			So(mux.Close(), ShouldBeNil)
+
+			demux := spipe.NewDemux(bytes.NewReader(wire.Bytes()), 3)
+
+			for i := len(inputs) - 1; i >= 0; i-- {
+				out, err := ioutil.ReadAll(demux.Stream(i))
+				So(err, ShouldBeNil)
+				So(string(out), ShouldEqual, inputs[i])
+			}
+
+			So(demux.Close(), ShouldBeNil)
+		})
+
+		Convey("streamed over a pipe", func() {
+			r, w := io.Pipe()
+			mux := spipe.NewMux(w, 2).FrameSize(3).QueueSize(1)
+			demux := spipe.NewDemux(r, 2).QueueSize(1)
+
+			go func() {
+				_, _ = mux.Stream(0).Write([]byte("stream zero data"))
+				_, _ = mux.Stream(1).Write([]byte("stream one data"))
+				_ = mux.Close()
+			}()
+
+			var wg sync.WaitGroup
+			outs := make([]string, 2)
+			for i := range outs {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					out, _ := ioutil.ReadAll(demux.Stream(i))
+					outs[i] = string(out)
+				}(i)
+			}
+			wg.Wait()
+
+			So(outs, ShouldResemble, []string{"stream zero data", "stream one data"})
+		})
+
+		Convey("fairness", func() {
+			log := &frameLog{gate: make(chan struct{})}
+			mux := spipe.NewMux(log, 2).FrameSize(1).QueueSize(8)
+
+			// Hold the output back until both streams have queued frames.
+			_, _ = mux.Stream(0).Write([]byte("aaaaaaaa"))
+			_, _ = mux.Stream(1).Write([]byte("bbb"))
+			_ = mux.Stream(0).Close()
+			_ = mux.Stream(1).Close()
+			close(log.gate)
+			So(mux.Close(), ShouldBeNil)
+
+			// The scheduler may have taken one frame before the second stream
+			// queued any, after that the streams alternate.
+			ids := string(log.ids)
+			So(ids[1:], ShouldEqual, "\x01\x00\x01\x00\x01\x00\x01\x00\x00\x00\x00\x00")
+		})
+
+		Convey("close with error", func() {
+			_, _ = mux.Stream(0).Write([]byte("partial"))
+			So(mux.Stream(0).CloseWithError(errors.New("disk full")), ShouldBeNil)
+			So(mux.Close(), ShouldBeNil)
+
+			demux := spipe.NewDemux(bytes.NewReader(wire.Bytes()), 3)
+			out, err := ioutil.ReadAll(demux.Stream(0))
+			So(string(out), ShouldEqual, "partial")
+
+			re := new(spipe.RemoteError)
+			So(errors.As(err, &re), ShouldBeTrue)
+			So(re.Message, ShouldEqual, "disk full")
+
+			out, err = ioutil.ReadAll(demux.Stream(1))
+			So(err, ShouldBeNil)
+			So(len(out), ShouldEqual, 0)
+		})
+
+		Convey("write after close", func() {
+			s := mux.Stream(1)
+			So(s.Close(), ShouldBeNil)
+			So(s.Close(), ShouldBeNil)
+
+			_, err := s.Write([]byte("a"))
+			So(err, ShouldEqual, os.ErrClosed)
+			So(mux.Close(), ShouldBeNil)
+			So(mux.Close(), ShouldBeNil)
+		})
+
+		Convey("output errors", func() {
+			out := &WriteCloser{WriteErrors: []error{io.ErrClosedPipe}}
+			mux := spipe.NewMux(out, 1).FrameSize(1).QueueSize(1)
+
+			_, err := mux.Stream(0).Write([]byte("abcdef"))
+			So(err, ShouldEqual, io.ErrClosedPipe)
+			So(mux.Close(), ShouldEqual, io.ErrClosedPipe)
+		})
+
+		Convey("truncated input", func() {
+			_, _ = mux.Stream(0).Write([]byte("data"))
+			So(mux.Close(), ShouldBeNil)
+
+			// Drop the close frames.
+			cut := wire.Bytes()[:wire.Len()-9]
+
+			demux := spipe.NewDemux(bytes.NewReader(cut), 3)
+			out, err := ioutil.ReadAll(demux.Stream(0))
+			So(string(out), ShouldEqual, "data")
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+
+			demux = spipe.NewDemux(bytes.NewReader(cut[:len(cut)-1]), 3)
+			_, err = ioutil.ReadAll(demux.Stream(0))
+			So(err, ShouldEqual, io.ErrUnexpectedEOF)
+		})
+
+		Convey("malformed input", func() {
+			for _, bad := range []string{
+				"\x07\x00\x00",         // unknown flag
+				"\x00\x05\x00",         // unknown stream
+				"\x00\x00\xff\xff\x7f", // oversized frame
+				"\x00" + strings.Repeat("\xff", 11),
+			} {
+				demux := spipe.NewDemux(strings.NewReader(bad), 3)
+				_, err := ioutil.ReadAll(demux.Stream(0))
+				So(err, ShouldEqual, spipe.ErrBadFrame)
+			}
+		})
+
+		Convey("closed streams are discarded", func() {
+			_, _ = mux.Stream(0).Write([]byte(inputs[2]))
+			_, _ = mux.Stream(1).Write([]byte("wanted"))
+			So(mux.Close(), ShouldBeNil)
+
+			demux := spipe.NewDemux(bytes.NewReader(wire.Bytes()), 3).QueueSize(1)
+			So(demux.Stream(0).Close(), ShouldBeNil)
+
+			out, err := ioutil.ReadAll(demux.Stream(1))
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "wanted")
+
+			_, err = demux.Stream(0).Read(make([]byte, 1))
+			So(err, ShouldEqual, os.ErrClosed)
+		})
+
+		Convey("flow control", func() {
+			data, dataW := io.Pipe()
+			credits, creditsW := io.Pipe()
+			mux := spipe.NewMux(dataW, 2).FrameSize(4).QueueSize(1).FlowControl(credits, 16)
+			demux := spipe.NewDemux(data, 2).QueueSize(1).FlowControl(creditsW, 16)
+
+			big := strings.Repeat("unread stream ", 100)
+
+			written := make(chan error, 1)
+			go func() {
+				_, err := mux.Stream(0).Write([]byte(big))
+				written <- err
+			}()
+
+			go func() {
+				_, _ = mux.Stream(1).Write([]byte(inputs[2]))
+				_ = mux.Stream(1).Close()
+			}()
+
+			Convey("one unread stream does not block the others", func() {
+				read := make(chan string, 1)
+				go func() {
+					out, _ := ioutil.ReadAll(demux.Stream(1))
+					read <- string(out)
+				}()
+
+				select {
+				case out := <-read:
+					So(out, ShouldEqual, inputs[2])
+				case <-time.After(5 * time.Second):
+					So("stream 1 stalled", ShouldBeEmpty)
+				}
+
+				// Stream 0 is held back by its window until it is read.
+				select {
+				case <-written:
+					So("stream 0 was not held back", ShouldBeEmpty)
+				default:
+				}
+
+				closed := make(chan error, 1)
+				go func() {
+					if err := <-written; err != nil {
+						closed <- err
+						return
+					}
+
+					closed <- mux.Close()
+				}()
+
+				out, err := ioutil.ReadAll(demux.Stream(0))
+				So(err, ShouldBeNil)
+				So(string(out), ShouldEqual, big)
+				So(<-closed, ShouldBeNil)
+			})
+
+			Convey("closed streams are granted their discarded frames", func() {
+				So(demux.Stream(0).Close(), ShouldBeNil)
+
+				out, err := ioutil.ReadAll(demux.Stream(1))
+				So(err, ShouldBeNil)
+				So(string(out), ShouldEqual, inputs[2])
+
+				So(<-written, ShouldBeNil)
+				So(mux.Close(), ShouldBeNil)
+			})
+		})
+
+		Convey("flow control without credit", func() {
+			for _, credits := range []string{"", "\x00\x00\x00", "\x03\x00\x01\x80"} {
+				mux := spipe.NewMux(ioutil.Discard, 1).FrameSize(4).QueueSize(1).
+					FlowControl(strings.NewReader(credits), 4)
+
+				_, err := mux.Stream(0).Write([]byte(inputs[2]))
+				So(err, ShouldNotBeNil)
+				So(mux.Close(), ShouldEqual, err)
+
+				if credits == "" {
+					So(err, ShouldEqual, io.ErrUnexpectedEOF)
+				} else {
+					So(err, ShouldEqual, spipe.ErrBadFrame)
+				}
+			}
+		})
+
+		Convey("credit frames are not stream data", func() {
+			demux := spipe.NewDemux(strings.NewReader("\x03\x00\x01\x04"), 1)
+			_, err := ioutil.ReadAll(demux.Stream(0))
+			So(err, ShouldEqual, spipe.ErrBadFrame)
+		})
+
+		Convey("demux close stops reading an input that is not a closer", func() {
+			r, w := io.Pipe()
+			demux := spipe.NewDemux(struct{ io.Reader }{r}, 2).QueueSize(1)
+
+			// Fill stream 0's queue so the reading goroutine blocks handing it
+			// the next frame.
+			mux := spipe.NewMux(w, 2).FrameSize(1)
+			go func() { _, _ = mux.Stream(0).Write([]byte("abc")) }()
+
+			go func() { _, _ = demux.Stream(1).Read(make([]byte, 1)) }()
+			time.Sleep(10 * time.Millisecond)
+
+			So(demux.Close(), ShouldBeNil)
+			time.Sleep(10 * time.Millisecond)
+
+			wrote := make(chan struct{})
+			go func() {
+				_, _ = w.Write([]byte("\x00\x01\x01x"))
+				close(wrote)
+			}()
+
+			select {
+			case <-wrote:
+				So("the input was read after Close", ShouldBeEmpty)
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			_ = r.Close()
+			<-wrote
+		})
+
+		Convey("demux close", func() {
+			r, w := io.Pipe()
+			demux := spipe.NewDemux(r, 1)
+
+			done := make(chan error)
+			go func() {
+				_, err := demux.Stream(0).Read(make([]byte, 1))
+				done <- err
+			}()
+
+			time.Sleep(10 * time.Millisecond)
+			So(demux.Close(), ShouldBeNil)
+			So(<-done, ShouldEqual, os.ErrClosed)
+
+			_, err := w.Write([]byte("x"))
+			So(err, ShouldEqual, io.ErrClosedPipe)
+		})
+	})
+}
@@ -0,0 +1,49 @@
+package spipe
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// PartFactory creates the output for the part with the given zero based index.
+type PartFactory func(index int) (io.WriteCloser, error)
+
+// FileParts returns a PartFactory that creates a file for each part, named by
+// formatting the part index with the given pattern.
+//
+// For example the pattern "app.%03d.log" will create the files "app.000.log",
+// "app.001.log", and so on.  Existing files are truncated.
+func FileParts(pattern string) PartFactory {
+	return func(index int) (io.WriteCloser, error) {
+		return os.Create(fmt.Sprintf(pattern, index))
+	}
+}
+
+// OpenFileParts returns a MultiReadCloser that reads back, in order, the files
+// created by a FileParts PartFactory with the same pattern.
+//
+// Files are looked up starting from index 0 until a file does not exist.  Each
+// file is opened lazily when it is reached and closed as soon as it has been
+// consumed.
+func OpenFileParts(pattern string) (MultiReadCloser, error) {
+	var inputs []io.ReadCloser
+
+	for i := 0; ; i++ {
+		name := fmt.Sprintf(pattern, i)
+
+		if _, err := os.Stat(name); err != nil {
+			if os.IsNotExist(err) {
+				break
+			}
+
+			return nil, err
+		}
+
+		inputs = append(inputs, NewLazyReadCloser(func() (io.ReadCloser, error) {
+			return os.Open(name)
+		}))
+	}
+
+	return NewMultiReadCloser(inputs...).CloseImmediately(true), nil
+}
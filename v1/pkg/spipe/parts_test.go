@@ -0,0 +1,50 @@
+package spipe_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestFileParts(t *testing.T) {
+	Convey("FileParts", t, func() {
+		dir, err := ioutil.TempDir("", "spipe")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+
+		pattern := filepath.Join(dir, "part.%03d")
+
+		Convey("round trip", func() {
+			w := spipe.NewRotateWriteCloser(spipe.FileParts(pattern)).MaxBytes(5)
+
+			_, err := w.Write([]byte("hello world, goodbye world"))
+			So(err, ShouldBeNil)
+			So(w.Close(), ShouldBeNil)
+
+			names, _ := filepath.Glob(filepath.Join(dir, "part.*"))
+			So(names, ShouldHaveLength, 6)
+
+			r, err := spipe.OpenFileParts(pattern)
+			So(err, ShouldBeNil)
+
+			out, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "hello world, goodbye world")
+			So(r.Close(), ShouldBeNil)
+		})
+
+		Convey("no parts", func() {
+			r, err := spipe.OpenFileParts(pattern)
+			So(err, ShouldBeNil)
+
+			out, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(out, ShouldBeEmpty)
+		})
+	})
+}
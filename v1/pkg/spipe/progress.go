@@ -0,0 +1,189 @@
+package spipe
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Progress describes how far a reader or writer has got through its data.
+type Progress struct {
+	// Bytes is the number of bytes read or written so far.
+	Bytes int64
+
+	// Total is the number of bytes expected overall, or -1 if it is not known.
+	Total int64
+
+	// Input is the position of the input currently being read, and equals
+	// Inputs once every input has been consumed.  Always 0 for writers.
+	Input int
+
+	// Inputs is the total number of inputs.  Always 0 for writers.
+	Inputs int
+
+	// Elapsed is the time since the first byte was read or written.
+	Elapsed time.Duration
+
+	// Rate is the average number of bytes per second over Elapsed.
+	Rate float64
+
+	// ETA is the estimated time remaining, or -1 if it cannot be estimated.
+	ETA time.Duration
+
+	// Done is set on the final report, made once the inputs are exhausted or
+	// the writer has been closed or has written Total bytes.
+	Done bool
+}
+
+// ProgressFunc receives progress reports from a reader or writer.  It is called
+// synchronously from the read or write path, and should return quickly.
+type ProgressFunc func(Progress)
+
+// StreamSize returns the number of bytes remaining in the given stream, or -1
+// if it cannot be determined.
+//
+// The size is taken from an io.Seeker's current and end offsets, from a Size
+// method such as that of *bytes.Reader, or from the Stat method of a regular
+// file, in that order.
+func StreamSize(stream interface{}) int64 {
+	if s, ok := stream.(io.Seeker); ok {
+		if n, ok := seekerSize(s); ok {
+			return n
+		}
+	}
+
+	if s, ok := stream.(interface{ Size() int64 }); ok {
+		return s.Size()
+	}
+
+	if s, ok := stream.(interface{ Stat() (os.FileInfo, error) }); ok {
+		if fi, err := s.Stat(); err == nil && fi.Mode().IsRegular() {
+			return fi.Size()
+		}
+	}
+
+	return -1
+}
+
+func seekerSize(s io.Seeker) (int64, bool) {
+	cur, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false
+	}
+
+	end, err := s.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, false
+	}
+
+	if _, err = s.Seek(cur, io.SeekStart); err != nil {
+		return 0, false
+	}
+
+	return end - cur, true
+}
+
+// progress tracks the bytes passing through a reader or writer and reports
+// them to a ProgressFunc at most once per interval.
+//
+// A nil progress tracks nothing.
+type progress struct {
+	fn       ProgressFunc
+	interval time.Duration
+	total    int64
+	inputs   int
+
+	bytes int64
+	start time.Time
+	last  time.Time
+	done  bool
+}
+
+func newProgress(interval time.Duration, fn ProgressFunc, total int64, inputs int) *progress {
+	if fn == nil {
+		return nil
+	}
+
+	return &progress{fn: fn, interval: interval, total: total, inputs: inputs}
+}
+
+// add records n more bytes, reporting if the interval has passed since the last
+// report.
+func (p *progress) add(n int, input int) {
+	if p == nil || n <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	if p.start.IsZero() {
+		p.start, p.last = now, now
+	}
+
+	p.bytes += int64(n)
+
+	// Writers have no end of input to detect, so they are finished once the
+	// expected total has been written.
+	if p.inputs == 0 && p.total >= 0 && p.bytes >= p.total {
+		p.finish(input)
+		return
+	}
+
+	if now.Sub(p.last) >= p.interval {
+		p.last = now
+		p.report(now, input)
+	}
+}
+
+// finish makes the final report, if it has not already been made.
+func (p *progress) finish(input int) {
+	if p == nil || p.done {
+		return
+	}
+
+	p.done = true
+	p.report(time.Now(), input)
+}
+
+func (p *progress) report(now time.Time, input int) {
+	out := Progress{
+		Bytes:  p.bytes,
+		Total:  p.total,
+		Input:  input,
+		Inputs: p.inputs,
+		ETA:    -1,
+		Done:   p.done,
+	}
+
+	if !p.start.IsZero() {
+		out.Elapsed = now.Sub(p.start)
+	}
+
+	if secs := out.Elapsed.Seconds(); secs > 0 {
+		out.Rate = float64(p.bytes) / secs
+	}
+
+	if p.done {
+		out.ETA = 0
+	} else if p.total >= 0 && out.Rate > 0 {
+		remaining := float64(p.total-p.bytes) / out.Rate
+		out.ETA = time.Duration(remaining * float64(time.Second))
+	}
+
+	p.fn(out)
+}
+
+// addSize adds the size of the given stream to the given running total, which
+// becomes -1 once the size of any stream is unknown.
+func addSize(total int64, stream interface{}) int64 {
+	if total < 0 {
+		return -1
+	}
+
+	n := StreamSize(stream)
+	if n < 0 {
+		return -1
+	}
+
+	return total + n
+}
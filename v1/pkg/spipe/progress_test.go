@@ -0,0 +1,215 @@
+package spipe_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+type sizer struct {
+	io.Reader
+	size int64
+}
+
+func (s sizer) Size() int64 {
+	return s.size
+}
+
+func TestStreamSize(t *testing.T) {
+	Convey("StreamSize", t, func() {
+		Convey("seeker", func() {
+			in := strings.NewReader("hello world")
+			_, _ = in.Read(make([]byte, 6))
+
+			So(spipe.StreamSize(in), ShouldEqual, 5)
+
+			rest, _ := ioutil.ReadAll(in)
+			So(string(rest), ShouldEqual, "world")
+		})
+
+		Convey("sizer", func() {
+			So(spipe.StreamSize(sizer{size: 42}), ShouldEqual, 42)
+		})
+
+		Convey("file", func() {
+			f, err := ioutil.TempFile("", "spipe-progress")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			_, _ = f.WriteString("hello")
+			_, _ = f.Seek(0, io.SeekStart)
+
+			So(spipe.StreamSize(f), ShouldEqual, 5)
+		})
+
+		Convey("unknown", func() {
+			So(spipe.StreamSize(new(bytes.Buffer)), ShouldEqual, -1)
+			So(spipe.StreamSize(nil), ShouldEqual, -1)
+		})
+	})
+}
+
+func TestMultiReadCloser_Progress(t *testing.T) {
+	Convey("MultiReadCloser.Progress", t, func() {
+		Convey("hidden sizes", func() {
+			var seen []spipe.Progress
+
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(strings.NewReader("hello")),
+				ioutil.NopCloser(sizer{strings.NewReader("world!"), 6}),
+			).Progress(0, func(p spipe.Progress) {
+				seen = append(seen, p)
+			})
+
+			// NopCloser hides the Size and Seek methods of the inputs.
+			_, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+
+			last := seen[len(seen)-1]
+			So(last.Done, ShouldBeTrue)
+			So(last.Total, ShouldEqual, -1)
+			So(last.ETA, ShouldEqual, 0)
+		})
+
+		Convey("reports", func() {
+			var seen []spipe.Progress
+
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(strings.NewReader("hello")),
+				ioutil.NopCloser(strings.NewReader("world!")),
+			).Progress(0, func(p spipe.Progress) {
+				seen = append(seen, p)
+			})
+
+			buff := make([]byte, 3)
+
+			_, err := test.Read(buff)
+			So(err, ShouldBeNil)
+			So(len(seen), ShouldEqual, 1)
+			So(seen[0].Bytes, ShouldEqual, 3)
+			So(seen[0].Input, ShouldEqual, 0)
+			So(seen[0].Inputs, ShouldEqual, 2)
+			So(seen[0].Done, ShouldBeFalse)
+
+			_, err = ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+
+			last := seen[len(seen)-1]
+			So(last.Bytes, ShouldEqual, 11)
+			So(last.Input, ShouldEqual, 2)
+			So(last.Done, ShouldBeTrue)
+
+			// The final report is only made once.
+			_, err = test.Read(buff)
+			So(err, ShouldEqual, io.EOF)
+			So(seen[len(seen)-1], ShouldResemble, last)
+		})
+
+		Convey("throttled", func() {
+			calls := 0
+
+			test := spipe.NewMultiReadCloser(
+				ioutil.NopCloser(strings.NewReader("hello world")),
+			).Progress(time.Hour, func(spipe.Progress) {
+				calls++
+			})
+
+			buff := make([]byte, 1)
+			for i := 0; i < 5; i++ {
+				_, _ = test.Read(buff)
+			}
+
+			So(calls, ShouldEqual, 0)
+		})
+	})
+}
+
+func TestMultiReader_Progress(t *testing.T) {
+	Convey("MultiReader.Progress", t, func() {
+		var seen []spipe.Progress
+
+		test := spipe.NewMultiReader(
+			strings.NewReader("hello"),
+			bytes.NewReader([]byte("world!")),
+		).Progress(0, func(p spipe.Progress) {
+			seen = append(seen, p)
+		})
+
+		_, err := test.Read(make([]byte, 2))
+		So(err, ShouldBeNil)
+		So(seen[0].Total, ShouldEqual, 11)
+		// No time has passed by the first report, so there is no rate yet.
+		So(seen[0].ETA, ShouldEqual, -1)
+
+		_, err = ioutil.ReadAll(test)
+		So(err, ShouldBeNil)
+		So(seen[len(seen)-1].Bytes, ShouldEqual, 11)
+		So(seen[len(seen)-1].Done, ShouldBeTrue)
+	})
+}
+
+func TestSplitWriter_Progress(t *testing.T) {
+	Convey("SplitWriter.Progress", t, func() {
+		var seen []spipe.Progress
+
+		test := spipe.NewSplitWriter(new(strings.Builder), new(strings.Builder)).
+			Progress(0, func(p spipe.Progress) {
+				seen = append(seen, p)
+			})
+
+		Convey("unknown total", func() {
+			_, _ = test.Write([]byte("hello"))
+
+			So(len(seen), ShouldEqual, 1)
+			So(seen[0].Bytes, ShouldEqual, 5)
+			So(seen[0].Total, ShouldEqual, -1)
+			So(seen[0].ETA, ShouldEqual, -1)
+			So(seen[0].Inputs, ShouldEqual, 0)
+		})
+
+		Convey("expected size", func() {
+			test.ExpectSize(7)
+
+			_, _ = test.WriteString("hello")
+			So(seen[0].Total, ShouldEqual, 7)
+			So(seen[0].Done, ShouldBeFalse)
+
+			_ = test.WriteByte(' ')
+			_ = test.WriteByte('!')
+			So(len(seen), ShouldEqual, 3)
+			So(seen[2].Done, ShouldBeTrue)
+
+			_, _ = test.Write([]byte("more"))
+			So(len(seen), ShouldEqual, 3)
+		})
+	})
+}
+
+func TestSplitWriteCloser_Progress(t *testing.T) {
+	Convey("SplitWriteCloser.Progress", t, func() {
+		var seen []spipe.Progress
+
+		test := spipe.NewSplitWriteCloser(new(WriteCloser), new(WriteCloser)).
+			Progress(time.Hour, func(p spipe.Progress) {
+				seen = append(seen, p)
+			})
+
+		_, _ = test.Write([]byte("hello"))
+		So(len(seen), ShouldEqual, 0)
+
+		So(test.Close(), ShouldBeNil)
+		So(len(seen), ShouldEqual, 1)
+		So(seen[0].Bytes, ShouldEqual, 5)
+		So(seen[0].Done, ShouldBeTrue)
+	})
+}
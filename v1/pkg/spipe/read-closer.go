@@ -1,6 +1,11 @@
 package spipe
 
-import "io"
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+)
 
 // MultiReadCloser defines an io.ReadCloser implementation that can read from
 // and close multiple input streams as if they were one long stream.
@@ -12,37 +17,118 @@ import "io"
 // closed, in order, as soon as they hit EOF.
 type MultiReadCloser interface {
 	io.ReadCloser
+	io.ByteScanner
+	io.RuneScanner
 
 	// CloseImmediately controls whether the input readers will be closed as soon
 	// as they are consumed rather than waiting for a Close call.
 	CloseImmediately(bool) MultiReadCloser
+
+	// Names sets the names used to identify the inputs in returned StreamError
+	// values.  Names are given in the same order as the inputs were given to
+	// the constructor.
+	Names(...string) MultiReadCloser
+
+	// Peek returns the next n bytes without consuming them, reading across
+	// input boundaries as needed.  The returned slice is only valid until the
+	// next read.  If fewer than n bytes are available, Peek returns them along
+	// with the error that stopped it, io.EOF if the inputs were exhausted.
+	Peek(n int) ([]byte, error)
+
+	// Instrument enables the collection of Metrics for the inputs, passing an
+	// Observation for every read to each of the given observers.  Instrument
+	// should be called before the first read.
+	Instrument(...Observer) MultiReadCloser
+
+	// Metrics returns a snapshot of the counters collected for the inputs.  The
+	// snapshot is empty if Instrument has not been called.
+	Metrics() Metrics
+
+	// Limit caps the overall rate at which bytes are read from the inputs.  A
+	// nil limiter removes the cap.
+	Limit(*Limiter) MultiReadCloser
+
+	// Context sets the context used to cancel waits on the limiter.  A
+	// cancelled wait is returned from Read as the context's error.  Defaults to
+	// context.Background().
+	Context(context.Context) MultiReadCloser
+
+	// Progress sets a function to be called with a progress report at most
+	// once per interval while reading, and once more when the inputs are
+	// exhausted.  The total is known if every input is an io.Seeker, has a
+	// Size method or is a regular file, see StreamSize.  Progress should be
+	// called before the first read.
+	Progress(interval time.Duration, fn ProgressFunc) MultiReadCloser
+
+	// Decompress wraps every input in a reader that recognises the input's
+	// compression format from its first bytes and decompresses it.  Inputs in
+	// none of the given formats, or of DefaultFormats if none are given, are
+	// read unchanged.  Each decompressor is closed before its input, whether
+	// on Close or, with CloseImmediately, once the input is consumed.
+	// Decompress should be called before the first read.
+	Decompress(formats ...Format) MultiReadCloser
 }
 
 // NewMultiReadCloser returns a new MultiReadCloser instance that will read from
 // the given inputs in the order they are passed.
+//
+// Errors returned from the inputs are wrapped in a *StreamError identifying
+// the failing input.
 func NewMultiReadCloser(inputs ...io.ReadCloser) MultiReadCloser {
 	return &multiReadCloser{inputs: inputs}
 }
 
 type multiReadCloser struct {
 	inputs   []io.ReadCloser
+	names    []string
 	aggClose bool
+	closed   bool
+
+	// popped is the number of inputs that have been consumed.
+	popped int
+
+	// consumed holds the inputs that have been consumed but not closed, as
+	// CloseImmediately was not set, so they can be closed by Close.
+	consumed []consumedInput
+
+	scan lookahead
+
+	// meter records reads from the inputs, nil unless instrumented.
+	meter *meter
+
+	limiter *Limiter
+	ctx     context.Context
+
+	progress *progress
 }
 
-func (m *multiReadCloser) Close() (err error) {
-	var errs []error
+// Close closes every input that has not already been closed.  Later calls
+// return nil, and reads after Close return os.ErrClosed.
+func (m *multiReadCloser) Close() error {
+	if m.closed {
+		return nil
+	}
 
-	for _, r := range m.inputs {
-		if e := r.Close(); e != nil {
-			errs = append(errs, e)
+	m.closed = true
+	errs := NewMultiErrorBuilder()
+
+	for _, c := range m.consumed {
+		if e := c.input.Close(); e != nil {
+			errs.Add(m.inputError(OpClose, c.index, e))
 		}
 	}
 
-	if len(errs) > 0 {
-		err = NewMultiError(errs)
+	m.consumed = nil
+
+	for i, r := range m.inputs {
+		if e := r.Close(); e != nil {
+			errs.Add(m.inputError(OpClose, m.popped+i, e))
+		}
 	}
 
-	return
+	m.inputs = nil
+
+	return errs.Build()
 }
 
 func (m *multiReadCloser) CloseImmediately(b bool) MultiReadCloser {
@@ -50,6 +136,11 @@ func (m *multiReadCloser) CloseImmediately(b bool) MultiReadCloser {
 	return m
 }
 
+func (m *multiReadCloser) Names(names ...string) MultiReadCloser {
+	m.names = names
+	return m
+}
+
 // Read attempts to fill the given buffer by reading from one or more available
 // streams until it runs out of input, or the len(p) bytes have been read.
 //
@@ -75,7 +166,49 @@ func (m *multiReadCloser) CloseImmediately(b bool) MultiReadCloser {
 //     buffer := make([]byte, 512)
 //     io.MultiReader(reader1, reader2).Read(buffer)
 func (m *multiReadCloser) Read(p []byte) (totalRead int, err error) {
-	return internalRead(m, p)
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+
+	return scanRead(m, &m.scan, p)
+}
+
+// ReadByte reads and returns the next byte from the inputs, moving on to the
+// next input as each one is exhausted.
+func (m *multiReadCloser) ReadByte() (byte, error) {
+	if m.closed {
+		return 0, os.ErrClosed
+	}
+
+	return scanReadByte(m, &m.scan)
+}
+
+// UnreadByte unreads the last byte consumed by Read, ReadByte or ReadRune.
+func (m *multiReadCloser) UnreadByte() error {
+	return scanUnreadByte(&m.scan)
+}
+
+// ReadRune reads and returns the next UTF-8 encoded rune from the inputs.  A
+// rune split between two inputs is decoded whole.
+func (m *multiReadCloser) ReadRune() (rune, int, error) {
+	if m.closed {
+		return 0, 0, os.ErrClosed
+	}
+
+	return scanReadRune(m, &m.scan)
+}
+
+// UnreadRune unreads the last rune consumed by ReadRune.
+func (m *multiReadCloser) UnreadRune() error {
+	return scanUnreadRune(&m.scan)
+}
+
+func (m *multiReadCloser) Peek(n int) ([]byte, error) {
+	if m.closed {
+		return nil, os.ErrClosed
+	}
+
+	return scanPeek(m, &m.scan, n)
 }
 
 func (m *multiReadCloser) hasNext() bool {
@@ -87,22 +220,88 @@ func (m *multiReadCloser) nextInput() io.Reader {
 }
 
 func (m *multiReadCloser) popInput() (err error) {
+	if m.aggClose {
+		err = m.inputs[0].Close()
+	} else {
+		m.consumed = append(m.consumed, consumedInput{index: m.popped, input: m.inputs[0]})
+	}
+
+	m.popped++
+
 	// 0 case is not possible due to hasNext call in read
 	if len(m.inputs) == 1 {
-		if m.aggClose {
-			err = m.inputs[0].Close()
-		}
-
 		m.inputs = nil
+	} else {
+		m.inputs = m.inputs[1:]
+	}
+
+	return
+}
+
+// consumedInput is an input that has been read to completion.
+type consumedInput struct {
+	index int
+	input io.ReadCloser
+}
+
+func (m *multiReadCloser) inputIndex() int {
+	return m.popped
+}
+
+func (m *multiReadCloser) Instrument(obs ...Observer) MultiReadCloser {
+	m.meter = newMeter(m.popped+len(m.inputs), inputRole, &m.names, obs)
+	return m
+}
+
+func (m *multiReadCloser) Metrics() Metrics {
+	return m.meter.snapshot()
+}
+
+func (m *multiReadCloser) inputMeter() *meter {
+	return m.meter
+}
 
-		return
+func (m *multiReadCloser) Limit(limiter *Limiter) MultiReadCloser {
+	m.limiter = limiter
+	return m
+}
+
+func (m *multiReadCloser) Context(ctx context.Context) MultiReadCloser {
+	m.ctx = ctx
+	return m
+}
+
+func (m *multiReadCloser) inputLimiter() *Limiter {
+	return m.limiter
+}
+
+func (m *multiReadCloser) waitContext() context.Context {
+	return orBackground(m.ctx)
+}
+
+func (m *multiReadCloser) Progress(interval time.Duration, fn ProgressFunc) MultiReadCloser {
+	var total int64
+
+	for _, in := range m.inputs {
+		total = addSize(total, in)
 	}
 
-	if m.aggClose {
-		err = m.inputs[0].Close()
+	m.progress = newProgress(interval, fn, total, m.popped+len(m.inputs))
+	return m
+}
+
+func (m *multiReadCloser) Decompress(formats ...Format) MultiReadCloser {
+	for i, in := range m.inputs {
+		m.inputs[i] = newDecompressReadCloser(in, formats)
 	}
 
-	m.inputs = m.inputs[1:]
+	return m
+}
 
-	return
+func (m *multiReadCloser) inputProgress() *progress {
+	return m.progress
+}
+
+func (m *multiReadCloser) inputError(op string, index int, err error) error {
+	return newStreamError(op, RoleInput, index, m.names, err)
 }
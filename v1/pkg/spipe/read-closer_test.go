@@ -109,7 +109,12 @@ func TestMultiReadCloser_Read(t *testing.T) {
 			So(okRead2.CloseCalls, ShouldEqual, 1)
 			So(badClose.ReadCalls, ShouldEqual, 1)
 			So(badClose.CloseCalls, ShouldEqual, 1)
-			So(e, ShouldResemble, errors.New("hola"))
+			So(e, ShouldResemble, &spipe.StreamError{
+				Role:  spipe.RoleInput,
+				Index: 2,
+				Op:    spipe.OpPopInput,
+				Err:   errors.New("hola"),
+			})
 		})
 	})
 }
@@ -134,6 +139,27 @@ func TestMultiReadCloser_Close(t *testing.T) {
 			So(val, ShouldEqual, len(readers))
 		})
 
+		Convey("consumed inputs", func() {
+			var closed []int
+			closer := func(i int) func() error {
+				return func() error { closed = append(closed, i); return nil }
+			}
+
+			test := spipe.NewMultiReadCloser(
+				testRc{Reader: strings.NewReader("a"), cl: closer(0)},
+				testRc{Reader: strings.NewReader("b"), cl: closer(1)},
+				testRc{Reader: strings.NewReader("c"), cl: closer(2)},
+			)
+
+			buf := make([]byte, 2)
+			_, _ = io.ReadFull(test, buf)
+			So(string(buf), ShouldEqual, "ab")
+			So(closed, ShouldBeEmpty)
+
+			So(test.Close(), ShouldBeNil)
+			So(closed, ShouldResemble, []int{0, 1, 2})
+		})
+
 		Convey("errors", func() {
 			fn := func() error { return errors.New("hi") }
 
@@ -149,13 +175,17 @@ func TestMultiReadCloser_Close(t *testing.T) {
 
 			So(ok, ShouldBeTrue)
 			So(errs, ShouldNotBeNil)
-			So(errs.Error(), ShouldEqual, "hi\nhi\nhi\nhi\nhi")
+			So(errs.Error(), ShouldEqual, "Close input 0: hi\n"+
+				"Close input 1: hi\n"+
+				"Close input 2: hi\n"+
+				"Close input 3: hi\n"+
+				"Close input 4: hi")
 			So(errs.Errors(), ShouldResemble, []error{
-				errors.New("hi"),
-				errors.New("hi"),
-				errors.New("hi"),
-				errors.New("hi"),
-				errors.New("hi"),
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 0, Op: spipe.OpClose, Err: errors.New("hi")},
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 1, Op: spipe.OpClose, Err: errors.New("hi")},
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 2, Op: spipe.OpClose, Err: errors.New("hi")},
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 3, Op: spipe.OpClose, Err: errors.New("hi")},
+				&spipe.StreamError{Role: spipe.RoleInput, Index: 4, Op: spipe.OpClose, Err: errors.New("hi")},
 			})
 		})
 	})
@@ -1,71 +1,133 @@
 package spipe
 
-import "io"
+import (
+	"context"
+	"io"
+)
 
 type reader interface {
 	io.Reader
 	hasNext() bool
 	nextInput() io.Reader
 	popInput() error
+
+	// inputIndex returns the position of the current input in the list of
+	// inputs the reader was constructed with.
+	inputIndex() int
+
+	// inputError wraps the given error in a StreamError attributed to the input
+	// at the given position.
+	inputError(op string, index int, err error) error
+
+	// inputMeter returns the meter recording calls to the inputs, or nil if the
+	// reader is not instrumented.
+	inputMeter() *meter
+
+	// inputLimiter returns the limiter for the inputs, or nil if reads are not
+	// rate limited.
+	inputLimiter() *Limiter
+
+	// waitContext returns the context used to cancel waits on the limiter.
+	waitContext() context.Context
+
+	// inputProgress returns the progress tracker for the reader, or nil if
+	// progress is not being reported.
+	inputProgress() *progress
 }
 
 func internalRead(r reader, p []byte) (totalRead int, err error) {
 	// If we have no more available readers, return an EOF.
 	if !r.hasNext() {
+		r.inputProgress().finish(r.inputIndex())
 		return 0, io.EOF
 	}
 
 	ln := len(p)
 	pos := 0
 
+	// eof records whether the current input ended, which it may do alongside
+	// the bytes that fill the buffer.
+	eof := false
+
+	limiter := r.inputLimiter()
+
 	// Read the current input until it EOFs or throws some other error.
 	for totalRead < ln {
-		n, e := r.nextInput().Read(p[pos:])
+		buf := p[pos:]
+
+		// Keep each read within the limiter's burst so a large buffer does not
+		// pull in more than the limiter would allow at once.
+		if limiter != nil && len(buf) > limiter.Burst() {
+			buf = buf[:limiter.Burst()]
+		}
+
+		n, e := r.inputMeter().observe(r.inputIndex(), OpRead, func() (int, error) {
+			return r.nextInput().Read(buf)
+		})
 		totalRead += n
+		r.inputProgress().add(n, r.inputIndex())
+
+		// Move the current position up by the number of bytes read, which may be
+		// returned alongside an error.
+		pos += n
+
+		// Pay the limiter for the bytes that were read.  They are returned to
+		// the caller even if the wait is cancelled, so they stay charged.
+		if limiter != nil && n > 0 {
+			if err = limiter.chargeN(r.waitContext(), n); err != nil {
+				return
+			}
+		}
 
 		// If the last read returned an error
 		if e != nil {
 			// And that error was an EOF, the stream is dead, skip out of the loop and
 			// continue.
 			if e == io.EOF {
+				eof = true
 				break
 			}
 
 			// And that error was not an EOF, return it and halt.
-			err = e
+			err = r.inputError(OpRead, r.inputIndex(), e)
 			return
 		}
+	}
 
-		// Move the current position up by the number of bytes read
-		pos += n
+	// If the loop filled the buffer and the input has not ended, then we have
+	// nothing more to do.
+	if totalRead >= ln && !eof {
+		return
 	}
 
-	// If the loop filled the buffer, then we have nothing more to do.
-	if totalRead >= ln {
+	// if the last read resulted in fewer bytes read than len(p), or the input
+	// ended, pop the dead reader out of the queue and try filling the remainder
+	// with the next reader (if any exist).  An input that returned io.EOF must
+	// not be read again, even if it returned data alongside it.
+	index := r.inputIndex()
+	if e := r.popInput(); e != nil {
+		err = r.inputError(OpPopInput, index, e)
 		return
 	}
 
-	// if the last read resulted in fewer bytes read than len(p), pop the dead
-	// reader out of the queue and try filling the remainder with the next reader
-	// (if any exist).
-	if err = r.popInput(); err != nil {
+	if totalRead >= ln {
 		return
 	}
 
 	// Try a read using the unwritten part of the input buffer.
-	n, err := r.Read(p[pos:])
+	n, err := internalRead(r, p[pos:])
+
+	// Append the number of additional bytes read from the recursive call to the
+	// overall read count.
+	totalRead += n
 
 	// If we got an EOF from our last read, then we have no input readers left
 	// to use to fill the input buffer.  If we have also read more than 0 bytes
-	// overall, clear the error for this return, they will get it on the next
-	// Read call (if one is made).
-	if n > 0 && err == io.EOF {
+	// overall, whether from this input or a later one, clear the error for this
+	// return, they will get it on the next Read call (if one is made).
+	if totalRead > 0 && err == io.EOF {
 		err = nil
 	}
 
-	// Append the number of additional bytes read from the recursive call to the
-	// overall read count.
-	totalRead += n
-
 	return
 }
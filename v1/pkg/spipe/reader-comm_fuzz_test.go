@@ -0,0 +1,210 @@
+//go:build go1.18
+// +build go1.18
+
+package spipe_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// errFuzz is the error injected by the fuzzed streams.
+var errFuzz = errors.New("fuzz failure")
+
+// fuzzReader returns its data in reads of at most max bytes, failing with
+// errFuzz once failAt bytes have been read if failAt is not negative.
+type fuzzReader struct {
+	data   []byte
+	pos    int
+	max    int
+	failAt int
+
+	// eofWithData returns io.EOF alongside the last bytes rather than from
+	// the following read.
+	eofWithData bool
+
+	// stall returns no data and no error from every other read.
+	stall   bool
+	stalled bool
+}
+
+func (r *fuzzReader) Read(p []byte) (int, error) {
+	if r.stall && !r.stalled && len(p) > 0 {
+		r.stalled = true
+		return 0, nil
+	}
+
+	r.stalled = false
+
+	end := len(r.data)
+	if r.failAt >= 0 {
+		end = r.failAt
+	}
+
+	if r.max > 0 && len(p) > r.max {
+		p = p[:r.max]
+	}
+
+	n := copy(p, r.data[r.pos:end])
+	r.pos += n
+
+	if r.pos == r.failAt {
+		return n, errFuzz
+	}
+
+	if r.pos == len(r.data) && (n == 0 || r.eofWithData) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// fuzzInputs builds a set of readers over data from plan, two bytes per
+// reader.  The first byte gives the reader's length and behaviour, the second
+// its read size and failure position.  The returned index is that of the first
+// failing reader, or -1 if none fail.
+func fuzzInputs(data, plan []byte) (inputs []*fuzzReader, failing int) {
+	failing = -1
+
+	for len(plan) >= 2 {
+		b0, b1 := plan[0], plan[1]
+		plan = plan[2:]
+
+		size := int(b0 & 0x1f)
+		if size > len(data) {
+			size = len(data)
+		}
+
+		in := &fuzzReader{
+			data:        data[:size],
+			max:         int(b1 & 0x0f),
+			failAt:      -1,
+			eofWithData: b0&0x20 != 0,
+			stall:       b0&0x40 != 0,
+		}
+
+		if b0&0x80 != 0 {
+			in.failAt = int(b1>>4) % (size + 1)
+
+			if failing < 0 {
+				failing = len(inputs)
+			}
+		}
+
+		inputs = append(inputs, in)
+		data = data[size:]
+	}
+
+	return
+}
+
+func FuzzMultiReader_Read(f *testing.F) {
+	f.Add([]byte("hello world"), []byte{0x05, 0x00, 0x06, 0x00}, []byte{4})
+	f.Add([]byte("hello world"), []byte{0x25, 0x02, 0x00, 0x00, 0x46, 0x13}, []byte{1, 7, 3})
+	f.Add([]byte("hello world"), []byte{0x05, 0x00, 0x86, 0x31, 0x05, 0x00}, []byte{2, 64})
+	f.Add([]byte("abcdefghijklmnopqrstuvwxyz"), []byte{0x3f, 0x00, 0xff, 0xff}, []byte{0, 100})
+
+	f.Fuzz(func(t *testing.T, data, plan, sizes []byte) {
+		refs, failing := fuzzInputs(data, plan)
+		readers := make([]io.Reader, len(refs))
+
+		for i, r := range refs {
+			readers[i] = r
+		}
+
+		want, wantErr := io.ReadAll(io.MultiReader(readers...))
+
+		constructors := map[string]func([]io.Reader) io.Reader{
+			"MultiReader": func(in []io.Reader) io.Reader {
+				return spipe.NewMultiReader(in...)
+			},
+			"MultiReadCloser": func(in []io.Reader) io.Reader {
+				closers := make([]io.ReadCloser, len(in))
+				for i, r := range in {
+					closers[i] = ioutil.NopCloser(r)
+				}
+
+				return spipe.NewMultiReadCloser(closers...)
+			},
+		}
+
+		for name, construct := range constructors {
+			inputs, _ := fuzzInputs(data, plan)
+			readers := make([]io.Reader, len(inputs))
+
+			for i, r := range inputs {
+				readers[i] = r
+			}
+
+			got, err := fuzzReadAll(t, name, construct(readers), sizes)
+
+			if !bytes.Equal(got, want) {
+				t.Fatalf("%s: read %q, want %q", name, got, want)
+			}
+
+			if (err == nil) != (wantErr == nil) {
+				t.Fatalf("%s: returned %v, want %v", name, err, wantErr)
+			}
+
+			if err == nil {
+				continue
+			}
+
+			var streamErr *spipe.StreamError
+			if !errors.As(err, &streamErr) || !errors.Is(err, errFuzz) || streamErr.Index != failing {
+				t.Fatalf("%s: returned %v, want a StreamError for input %d wrapping %v", name, err, failing, errFuzz)
+			}
+		}
+	})
+}
+
+// fuzzReadAll reads r to the end using buffers with the given sizes in turn,
+// checking the buffer filling rules on each read.  io.EOF is not returned.
+func fuzzReadAll(t *testing.T, name string, r io.Reader, sizes []byte) ([]byte, error) {
+	var out []byte
+	short := false
+
+	for i := 0; ; i++ {
+		if i > 10000 {
+			t.Fatalf("%s: no end after %d reads", name, i)
+		}
+
+		size := 16
+		if len(sizes) > 0 {
+			size = 1 + int(sizes[i%len(sizes)])%64
+		}
+
+		buf := make([]byte, size)
+		n, err := r.Read(buf)
+
+		if n < 0 || n > size {
+			t.Fatalf("%s: read returned n = %d for a %d byte buffer", name, n, size)
+		}
+
+		if short && (n != 0 || err != io.EOF) {
+			t.Fatalf("%s: read after a short read returned %d, %v, want 0, io.EOF", name, n, err)
+		}
+
+		out = append(out, buf[:n]...)
+
+		if err == io.EOF {
+			if n > 0 {
+				t.Fatalf("%s: read returned io.EOF with %d bytes", name, n)
+			}
+
+			return out, nil
+		}
+
+		if err != nil {
+			return out, err
+		}
+
+		// The buffer is only left unfilled once every input is exhausted.
+		short = n < size
+	}
+}
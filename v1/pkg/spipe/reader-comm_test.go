@@ -4,9 +4,13 @@ import (
 	"errors"
 	"io"
 	"strings"
+	stdiotest "testing/iotest"
 
 	. "github.com/smartystreets/goconvey/convey"
 	"github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipetest"
 )
 
 func tReaderComm(construct func(interface{}) io.Reader) {
@@ -34,6 +38,67 @@ func tReaderComm(construct func(interface{}) io.Reader) {
 		So(n, ShouldEqual, 0)
 	})
 
+	Convey("data returned with EOF", func() {
+		readers := []io.Reader{
+			stdiotest.DataErrReader(strings.NewReader("abc")),
+			stdiotest.DataErrReader(strings.NewReader("def")),
+		}
+
+		test := construct(readers)
+		buff := make([]byte, 10)
+
+		n, e := test.Read(buff)
+
+		So(e, ShouldBeNil)
+		So(n, ShouldEqual, 6)
+		So(string(buff[:n]), ShouldEqual, "abcdef")
+	})
+
+	Convey("last input ending part way through the buffer", func() {
+		readers := []io.Reader{
+			strings.NewReader("abc"),
+			strings.NewReader("def"),
+		}
+
+		test := construct(readers)
+		buff := make([]byte, 4)
+
+		n, e := test.Read(buff)
+
+		So(e, ShouldBeNil)
+		So(string(buff[:n]), ShouldEqual, "abcd")
+
+		n, e = test.Read(buff)
+
+		So(e, ShouldBeNil)
+		So(string(buff[:n]), ShouldEqual, "ef")
+
+		n, e = test.Read(buff)
+
+		So(e, ShouldEqual, io.EOF)
+		So(n, ShouldEqual, 0)
+	})
+
+	Convey("data returned with EOF filling the buffer", func() {
+		readers := []io.Reader{
+			spipetest.NewReader("abcdef", spipetest.Step{Max: 3, Err: io.EOF}),
+			strings.NewReader("xyz"),
+		}
+
+		test := construct(readers)
+		buff := make([]byte, 3)
+
+		n, e := test.Read(buff)
+
+		So(e, ShouldBeNil)
+		So(string(buff[:n]), ShouldEqual, "abc")
+
+		n, e = test.Read(buff)
+
+		So(e, ShouldBeNil)
+		So(string(buff[:n]), ShouldEqual, "xyz")
+	})
+
 	Convey("chunk read", func() {
 		readers := []io.Reader{
 			strings.NewReader("abc"),
@@ -89,7 +154,12 @@ func tReaderComm(construct func(interface{}) io.Reader) {
 
 		_, e := test.Read(buff)
 
-		So(e, ShouldResemble, errors.New("hola"))
+		So(e, ShouldResemble, &spipe.StreamError{
+			Role:  spipe.RoleInput,
+			Index: 2,
+			Op:    spipe.OpRead,
+			Err:   errors.New("hola"),
+		})
 	})
 
 	Convey("repeating reader", func() {
@@ -0,0 +1,191 @@
+package spipe
+
+import (
+	"bufio"
+	"io"
+	"unicode/utf8"
+)
+
+// lookahead holds bytes that have been read from a multi-reader's inputs ahead
+// of the caller by Peek, ReadByte or ReadRune.
+//
+// Only as many bytes as are needed to satisfy each call are read ahead, so
+// inputs are never consumed further than the caller has asked to see.
+type lookahead struct {
+	// data[r:w] holds the unconsumed bytes.
+	data []byte
+	r, w int
+
+	// lastByte is the most recently consumed byte, valid if hasLast is set.
+	lastByte byte
+	hasLast  bool
+
+	// runeSize is the size of the rune consumed by the last call to ReadRune,
+	// or 0 if the last call was not a ReadRune.
+	runeSize int
+}
+
+func (l *lookahead) buffered() int {
+	return l.w - l.r
+}
+
+func (l *lookahead) forget() {
+	l.hasLast = false
+	l.runeSize = 0
+}
+
+// fill reads from the given reader until at least n bytes are buffered or the
+// reader returns an error.
+func (l *lookahead) fill(rd reader, n int) error {
+	if l.buffered() >= n {
+		return nil
+	}
+
+	// Slide the unconsumed bytes to the front of the buffer.
+	if l.r > 0 {
+		copy(l.data, l.data[l.r:l.w])
+		l.w -= l.r
+		l.r = 0
+	}
+
+	if len(l.data) < n {
+		grown := make([]byte, n)
+		copy(grown, l.data[:l.w])
+		l.data = grown
+	}
+
+	for l.w < n {
+		m, err := internalRead(rd, l.data[l.w:n])
+		l.w += m
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// scanRead reads into p from the bytes held by the given lookahead first, and
+// then from the given reader.
+func scanRead(rd reader, l *lookahead, p []byte) (n int, err error) {
+	if l.buffered() == 0 {
+		n, err = internalRead(rd, p)
+	} else {
+		n = copy(p, l.data[l.r:l.w])
+		l.r += n
+
+		// Having returned buffered bytes, an EOF from the inputs is held back
+		// until the next call.
+		if n < len(p) && rd.hasNext() {
+			var m int
+			if m, err = internalRead(rd, p[n:]); err == io.EOF {
+				err = nil
+			}
+			n += m
+		}
+	}
+
+	l.forget()
+
+	if n > 0 {
+		l.lastByte = p[n-1]
+		l.hasLast = true
+	}
+
+	return
+}
+
+func scanReadByte(rd reader, l *lookahead) (byte, error) {
+	l.forget()
+
+	if err := l.fill(rd, 1); err != nil {
+		return 0, err
+	}
+
+	c := l.data[l.r]
+	l.r++
+	l.lastByte = c
+	l.hasLast = true
+
+	return c, nil
+}
+
+func scanUnreadByte(l *lookahead) error {
+	if !l.hasLast {
+		return bufio.ErrInvalidUnreadByte
+	}
+
+	if l.r > 0 {
+		l.r--
+	} else {
+		// The byte came straight from an input rather than through the buffer,
+		// so make room for it at the front.
+		if l.w == len(l.data) {
+			l.data = append(l.data, 0)
+		}
+
+		copy(l.data[1:], l.data[:l.w])
+		l.w++
+	}
+
+	l.data[l.r] = l.lastByte
+	l.forget()
+
+	return nil
+}
+
+func scanReadRune(rd reader, l *lookahead) (r rune, size int, err error) {
+	l.forget()
+
+	// Read one byte at a time until a full rune is buffered so a rune split
+	// between two inputs is decoded whole.
+	for !utf8.FullRune(l.data[l.r:l.w]) {
+		if err = l.fill(rd, l.buffered()+1); err != nil {
+			break
+		}
+	}
+
+	if l.buffered() == 0 || (err != nil && err != io.EOF) {
+		return 0, 0, err
+	}
+
+	r, size = rune(l.data[l.r]), 1
+	if r >= utf8.RuneSelf {
+		r, size = utf8.DecodeRune(l.data[l.r:l.w])
+	}
+
+	l.r += size
+	l.lastByte = l.data[l.r-1]
+	l.hasLast = true
+	l.runeSize = size
+
+	return r, size, nil
+}
+
+func scanUnreadRune(l *lookahead) error {
+	if l.runeSize == 0 {
+		return bufio.ErrInvalidUnreadRune
+	}
+
+	l.r -= l.runeSize
+	l.forget()
+
+	return nil
+}
+
+func scanPeek(rd reader, l *lookahead, n int) ([]byte, error) {
+	if n < 0 {
+		return nil, bufio.ErrNegativeCount
+	}
+
+	l.forget()
+
+	err := l.fill(rd, n)
+
+	if avail := l.buffered(); avail < n {
+		return l.data[l.r:l.w], err
+	}
+
+	return l.data[l.r : l.r+n], nil
+}
@@ -0,0 +1,192 @@
+package spipe_test
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+type scanReader interface {
+	io.Reader
+	io.ByteScanner
+	io.RuneScanner
+	Peek(int) ([]byte, error)
+}
+
+func tReaderScan(construct func(...io.Reader) scanReader) {
+	Convey("ReadByte", func() {
+		test := construct(
+			strings.NewReader("ab"),
+			strings.NewReader(""),
+			iotest.OneByteReader(strings.NewReader("cd")),
+		)
+
+		out := ""
+		for {
+			c, err := test.ReadByte()
+			if err != nil {
+				So(err, ShouldEqual, io.EOF)
+				break
+			}
+			out += string(c)
+		}
+
+		So(out, ShouldEqual, "abcd")
+	})
+
+	Convey("UnreadByte", func() {
+		test := construct(strings.NewReader("ab"), strings.NewReader("cd"))
+
+		So(test.UnreadByte(), ShouldEqual, bufio.ErrInvalidUnreadByte)
+
+		c, _ := test.ReadByte()
+		So(c, ShouldEqual, 'a')
+		So(test.UnreadByte(), ShouldBeNil)
+		So(test.UnreadByte(), ShouldEqual, bufio.ErrInvalidUnreadByte)
+
+		Convey("after Read", func() {
+			buff := make([]byte, 3)
+			n, err := test.Read(buff)
+
+			So(err, ShouldBeNil)
+			So(string(buff[:n]), ShouldEqual, "abc")
+			So(test.UnreadByte(), ShouldBeNil)
+
+			out, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "cd")
+		})
+	})
+
+	Convey("ReadRune", func() {
+		Convey("split between inputs", func() {
+			// "é" is encoded as 0xC3 0xA9, "世" as 0xE4 0xB8 0x96.
+			test := construct(
+				strings.NewReader("a\xc3"),
+				strings.NewReader("\xa9\xe4"),
+				strings.NewReader(""),
+				strings.NewReader("\xb8"),
+				strings.NewReader("\x96!"),
+			)
+
+			var runes []rune
+			var sizes []int
+			for {
+				r, size, err := test.ReadRune()
+				if err != nil {
+					So(err, ShouldEqual, io.EOF)
+					break
+				}
+				runes = append(runes, r)
+				sizes = append(sizes, size)
+			}
+
+			So(string(runes), ShouldEqual, "aé世!")
+			So(sizes, ShouldResemble, []int{1, 2, 3, 1})
+		})
+
+		Convey("invalid trailing bytes", func() {
+			test := construct(strings.NewReader("\xe4\xb8"))
+
+			r, size, err := test.ReadRune()
+
+			So(err, ShouldBeNil)
+			So(r, ShouldEqual, '�')
+			So(size, ShouldEqual, 1)
+		})
+
+		Convey("UnreadRune", func() {
+			test := construct(strings.NewReader("\xc3"), strings.NewReader("\xa9z"))
+
+			So(test.UnreadRune(), ShouldEqual, bufio.ErrInvalidUnreadRune)
+
+			r, _, _ := test.ReadRune()
+			So(r, ShouldEqual, 'é')
+			So(test.UnreadRune(), ShouldBeNil)
+			So(test.UnreadRune(), ShouldEqual, bufio.ErrInvalidUnreadRune)
+
+			out, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "éz")
+		})
+	})
+
+	Convey("Peek", func() {
+		test := construct(
+			strings.NewReader("ab"),
+			strings.NewReader("cd"),
+			strings.NewReader("ef"),
+		)
+
+		p, err := test.Peek(3)
+		So(err, ShouldBeNil)
+		So(string(p), ShouldEqual, "abc")
+
+		p, err = test.Peek(5)
+		So(err, ShouldBeNil)
+		So(string(p), ShouldEqual, "abcde")
+
+		c, _ := test.ReadByte()
+		So(c, ShouldEqual, 'a')
+
+		buff := make([]byte, 2)
+		n, err := test.Read(buff)
+		So(err, ShouldBeNil)
+		So(string(buff[:n]), ShouldEqual, "bc")
+
+		p, err = test.Peek(10)
+		So(err, ShouldEqual, io.EOF)
+		So(string(p), ShouldEqual, "def")
+
+		out, err := ioutil.ReadAll(test)
+		So(err, ShouldBeNil)
+		So(string(out), ShouldEqual, "def")
+
+		_, err = test.Peek(-1)
+		So(err, ShouldEqual, bufio.ErrNegativeCount)
+	})
+
+	Convey("Read after Peek fills across inputs", func() {
+		test := construct(strings.NewReader("ab"), strings.NewReader("cd"))
+
+		_, _ = test.Peek(1)
+
+		buff := make([]byte, 4)
+		n, err := test.Read(buff)
+
+		So(err, ShouldBeNil)
+		So(n, ShouldEqual, 4)
+		So(string(buff), ShouldEqual, "abcd")
+
+		n, err = test.Read(buff)
+		So(n, ShouldEqual, 0)
+		So(err, ShouldEqual, io.EOF)
+	})
+}
+
+func TestMultiReader_Scan(t *testing.T) {
+	Convey("MultiReader scanning", t, func() {
+		tReaderScan(func(inputs ...io.Reader) scanReader {
+			return spipe.NewMultiReader(inputs...)
+		})
+	})
+}
+
+func TestMultiReadCloser_Scan(t *testing.T) {
+	Convey("MultiReadCloser scanning", t, func() {
+		tReaderScan(func(inputs ...io.Reader) scanReader {
+			par := make([]io.ReadCloser, len(inputs))
+			for i, r := range inputs {
+				par[i] = ioutil.NopCloser(r)
+			}
+			return spipe.NewMultiReadCloser(par...)
+		})
+	})
+}
@@ -1,6 +1,10 @@
 package spipe
 
-import "io"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 // MultiReader defines an io.Reader implementation that can read from multiple
 // input streams as if they were one long stream.
@@ -9,6 +13,44 @@ import "io"
 // the MultiReader instance.
 type MultiReader interface {
 	io.Reader
+	io.ByteScanner
+	io.RuneScanner
+
+	// Names sets the names used to identify the inputs in returned StreamError
+	// values.  Names are given in the same order as the inputs were given to
+	// the constructor.
+	Names(...string) MultiReader
+
+	// Peek returns the next n bytes without consuming them, reading across
+	// input boundaries as needed.  The returned slice is only valid until the
+	// next read.  If fewer than n bytes are available, Peek returns them along
+	// with the error that stopped it, io.EOF if the inputs were exhausted.
+	Peek(n int) ([]byte, error)
+
+	// Instrument enables the collection of Metrics for the inputs, passing an
+	// Observation for every read to each of the given observers.  Instrument
+	// should be called before the first read.
+	Instrument(...Observer) MultiReader
+
+	// Metrics returns a snapshot of the counters collected for the inputs.  The
+	// snapshot is empty if Instrument has not been called.
+	Metrics() Metrics
+
+	// Limit caps the overall rate at which bytes are read from the inputs.  A
+	// nil limiter removes the cap.
+	Limit(*Limiter) MultiReader
+
+	// Context sets the context used to cancel waits on the limiter.  A
+	// cancelled wait is returned from Read as the context's error.  Defaults to
+	// context.Background().
+	Context(context.Context) MultiReader
+
+	// Progress sets a function to be called with a progress report at most
+	// once per interval while reading, and once more when the inputs are
+	// exhausted.  The total is known if every input is an io.Seeker, has a
+	// Size method or is a regular file, see StreamSize.  Progress should be
+	// called before the first read.
+	Progress(interval time.Duration, fn ProgressFunc) MultiReader
 }
 
 // NewMultiReader returns a new MultiReader instance that will read from the
@@ -30,12 +72,29 @@ type MultiReader interface {
 // than it is to
 //     buffer := make([]byte, 512)
 //     io.MultiReader(reader1, reader2).Read(buffer)
+//
+// Errors returned from the inputs are wrapped in a *StreamError identifying
+// the failing input.
 func NewMultiReader(inputs ...io.Reader) MultiReader {
-	return &multiReader{inputs}
+	return &multiReader{inputs: inputs}
 }
 
 type multiReader struct {
 	inputs []io.Reader
+	names  []string
+
+	// popped is the number of inputs that have been consumed.
+	popped int
+
+	scan lookahead
+
+	// meter records reads from the inputs, nil unless instrumented.
+	meter *meter
+
+	limiter *Limiter
+	ctx     context.Context
+
+	progress *progress
 }
 
 // Read attempts to fill the given buffer by reading from one or more available
@@ -51,7 +110,38 @@ type multiReader struct {
 // this method will automatically continue on to the next stream in a single
 // call to Read in order to fill the input buffer.
 func (m *multiReader) Read(p []byte) (totalRead int, err error) {
-	return internalRead(m, p)
+	return scanRead(m, &m.scan, p)
+}
+
+// ReadByte reads and returns the next byte from the inputs, moving on to the
+// next input as each one is exhausted.
+func (m *multiReader) ReadByte() (byte, error) {
+	return scanReadByte(m, &m.scan)
+}
+
+// UnreadByte unreads the last byte consumed by Read, ReadByte or ReadRune.
+func (m *multiReader) UnreadByte() error {
+	return scanUnreadByte(&m.scan)
+}
+
+// ReadRune reads and returns the next UTF-8 encoded rune from the inputs.  A
+// rune split between two inputs is decoded whole.
+func (m *multiReader) ReadRune() (rune, int, error) {
+	return scanReadRune(m, &m.scan)
+}
+
+// UnreadRune unreads the last rune consumed by ReadRune.
+func (m *multiReader) UnreadRune() error {
+	return scanUnreadRune(&m.scan)
+}
+
+func (m *multiReader) Peek(n int) ([]byte, error) {
+	return scanPeek(m, &m.scan, n)
+}
+
+func (m *multiReader) Names(names ...string) MultiReader {
+	m.names = names
+	return m
 }
 
 func (m *multiReader) hasNext() bool {
@@ -63,6 +153,8 @@ func (m *multiReader) nextInput() io.Reader {
 }
 
 func (m *multiReader) popInput() (_ error) {
+	m.popped++
+
 	if len(m.inputs) < 2 {
 		m.inputs = nil
 		return
@@ -76,3 +168,57 @@ func (m *multiReader) popInput() (_ error) {
 
 	return
 }
+
+func (m *multiReader) inputIndex() int {
+	return m.popped
+}
+
+func (m *multiReader) Instrument(obs ...Observer) MultiReader {
+	m.meter = newMeter(m.popped+len(m.inputs), inputRole, &m.names, obs)
+	return m
+}
+
+func (m *multiReader) Metrics() Metrics {
+	return m.meter.snapshot()
+}
+
+func (m *multiReader) inputMeter() *meter {
+	return m.meter
+}
+
+func (m *multiReader) Limit(limiter *Limiter) MultiReader {
+	m.limiter = limiter
+	return m
+}
+
+func (m *multiReader) Context(ctx context.Context) MultiReader {
+	m.ctx = ctx
+	return m
+}
+
+func (m *multiReader) inputLimiter() *Limiter {
+	return m.limiter
+}
+
+func (m *multiReader) waitContext() context.Context {
+	return orBackground(m.ctx)
+}
+
+func (m *multiReader) Progress(interval time.Duration, fn ProgressFunc) MultiReader {
+	var total int64
+
+	for _, in := range m.inputs {
+		total = addSize(total, in)
+	}
+
+	m.progress = newProgress(interval, fn, total, m.popped+len(m.inputs))
+	return m
+}
+
+func (m *multiReader) inputProgress() *progress {
+	return m.progress
+}
+
+func (m *multiReader) inputError(op string, index int, err error) error {
+	return newStreamError(op, RoleInput, index, m.names, err)
+}
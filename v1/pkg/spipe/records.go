@@ -0,0 +1,75 @@
+package spipe
+
+import "bytes"
+
+// DefaultDelimiter is the record delimiter used by the record framed writers
+// when no other delimiter has been configured.
+const DefaultDelimiter = '\n'
+
+// recordFramer splits a byte stream into delimiter terminated records.
+//
+// Bytes following the last delimiter in a write are held back until a later
+// write completes the record, or until the framer is flushed.
+type recordFramer struct {
+	delim   byte
+	partial []byte
+}
+
+// frame passes every complete record in p, delimiter included, to the given
+// emit function.
+//
+// The record slice passed to emit is only valid for the duration of the call.
+//
+// Returns the number of bytes from p that were consumed before emit returned
+// an error.  If no error occurred, n will be len(p).
+func (f *recordFramer) frame(p []byte, emit func([]byte) error) (n int, err error) {
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, f.delim)
+
+		// No delimiter, hold the remainder until the record is completed.
+		if i < 0 {
+			f.partial = append(f.partial, p...)
+			n += len(p)
+			return
+		}
+
+		rec := p[:i+1]
+
+		if len(f.partial) > 0 {
+			f.partial = append(f.partial, rec...)
+			rec = f.partial
+		}
+
+		err = emit(rec)
+		f.partial = f.partial[:0]
+		n += i + 1
+		p = p[i+1:]
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// flush passes any held back partial record to the given emit function.
+func (f *recordFramer) flush(emit func([]byte) error) error {
+	if len(f.partial) == 0 {
+		return nil
+	}
+
+	rec := f.partial
+	f.partial = f.partial[:0]
+
+	return emit(rec)
+}
+
+// trimDelimiter returns the given record without its trailing delimiter.
+func trimDelimiter(rec []byte, delim byte) []byte {
+	if ln := len(rec); ln > 0 && rec[ln-1] == delim {
+		return rec[:ln-1]
+	}
+
+	return rec
+}
@@ -0,0 +1,268 @@
+package spipe
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// RotateHook is called after a part has been closed, with the index of that
+// part.
+//
+// Hooks can be used to run post-rotate actions such as compressing or
+// uploading the finished part.
+type RotateHook func(index int) error
+
+// RotateWriteCloser defines an io.WriteCloser implementation that splits a
+// single stream into numbered parts, moving on to a new part when the current
+// one reaches a byte limit, a record limit, or an age limit.
+//
+// Parts are created by a PartFactory the first time bytes are written to them,
+// so a part is never created empty.
+//
+// When record framing is enabled, parts are only rotated on record boundaries
+// and bytes following the last delimiter of a write are held until the record
+// is completed by a later write or until Close is called.
+type RotateWriteCloser interface {
+	io.WriteCloser
+
+	// MaxBytes sets the maximum number of bytes written to each part.  A value
+	// of 0 disables the limit.
+	//
+	// When record framing is enabled, a single record larger than the limit is
+	// written to a part of its own.
+	MaxBytes(int64) RotateWriteCloser
+
+	// MaxRecords sets the maximum number of records written to each part.  A
+	// value of 0 disables the limit.
+	//
+	// This limit only has an effect when record framing is enabled.
+	MaxRecords(int64) RotateWriteCloser
+
+	// Interval sets the maximum age of each part, measured from when the part
+	// was created.  A value of 0 disables the limit.
+	//
+	// Age is checked on each write, an idle part will not be rotated until the
+	// next write arrives.
+	Interval(time.Duration) RotateWriteCloser
+
+	// Records sets whether the stream should be split into records, so that
+	// parts are only rotated on record boundaries.
+	Records(bool) RotateWriteCloser
+
+	// Delimiter sets the byte used to separate records.  Defaults to
+	// DefaultDelimiter.
+	Delimiter(byte) RotateWriteCloser
+
+	// OnRotate sets a hook to be called after each part is closed, including
+	// the last part closed by Close.
+	OnRotate(RotateHook) RotateWriteCloser
+
+	// Rotate closes the current part, the next write will create a new one.
+	Rotate() error
+
+	// Part returns the index of the current part, or of the next part if the
+	// current one has not been created yet.
+	Part() int
+}
+
+// NewRotateWriteCloser constructs a new RotateWriteCloser instance that will
+// create its parts with the given PartFactory.
+func NewRotateWriteCloser(parts PartFactory) RotateWriteCloser {
+	return &rotateWriteCloser{
+		parts:  parts,
+		framer: recordFramer{delim: DefaultDelimiter},
+	}
+}
+
+type rotateWriteCloser struct {
+	parts      PartFactory
+	framer     recordFramer
+	hook       RotateHook
+	maxBytes   int64
+	maxRecords int64
+	interval   time.Duration
+	records    bool
+
+	current io.WriteCloser
+	index   int
+	opened  time.Time
+	written int64
+	count   int64
+	closed  bool
+}
+
+// Write writes the given bytes to the current part, rotating to new parts as
+// the configured limits are reached.
+//
+// When record framing is enabled, the returned byte count is the number of
+// bytes consumed from p, which includes bytes held back as part of an
+// incomplete record.
+func (r *rotateWriteCloser) Write(p []byte) (n int, err error) {
+	if r.closed {
+		return 0, os.ErrClosed
+	}
+
+	if r.records {
+		return r.framer.frame(p, r.writeRecord)
+	}
+
+	for len(p) > 0 {
+		if err = r.prepare(0); err != nil {
+			return
+		}
+
+		chunk := p
+		if r.maxBytes > 0 && int64(len(chunk)) > r.maxBytes-r.written {
+			chunk = chunk[:r.maxBytes-r.written]
+		}
+
+		m, e := r.current.Write(chunk)
+		n += m
+		r.written += int64(m)
+
+		if e != nil {
+			return n, e
+		}
+
+		if m < len(chunk) {
+			return n, io.ErrShortWrite
+		}
+
+		p = p[len(chunk):]
+	}
+
+	return
+}
+
+// Close writes out any held partial record and closes the current part.  Later
+// calls return nil, and writes after Close return os.ErrClosed.
+func (r *rotateWriteCloser) Close() error {
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+
+	if err := r.framer.flush(r.writeRecord); err != nil {
+		return err
+	}
+
+	return r.Rotate()
+}
+
+func (r *rotateWriteCloser) Rotate() error {
+	if r.current == nil {
+		return nil
+	}
+
+	err := r.current.Close()
+	r.current = nil
+	r.index++
+
+	if err != nil {
+		return err
+	}
+
+	if r.hook != nil {
+		return r.hook(r.index - 1)
+	}
+
+	return nil
+}
+
+func (r *rotateWriteCloser) Part() int {
+	return r.index
+}
+
+func (r *rotateWriteCloser) MaxBytes(n int64) RotateWriteCloser {
+	r.maxBytes = n
+	return r
+}
+
+func (r *rotateWriteCloser) MaxRecords(n int64) RotateWriteCloser {
+	r.maxRecords = n
+	return r
+}
+
+func (r *rotateWriteCloser) Interval(d time.Duration) RotateWriteCloser {
+	r.interval = d
+	return r
+}
+
+func (r *rotateWriteCloser) Records(b bool) RotateWriteCloser {
+	r.records = b
+	return r
+}
+
+func (r *rotateWriteCloser) Delimiter(b byte) RotateWriteCloser {
+	r.framer.delim = b
+	return r
+}
+
+func (r *rotateWriteCloser) OnRotate(fn RotateHook) RotateWriteCloser {
+	r.hook = fn
+	return r
+}
+
+func (r *rotateWriteCloser) writeRecord(rec []byte) error {
+	if err := r.prepare(int64(len(rec))); err != nil {
+		return err
+	}
+
+	if err := writeRecordTo(r.current, rec); err != nil {
+		return err
+	}
+
+	r.written += int64(len(rec))
+	r.count++
+
+	return nil
+}
+
+// prepare rotates the current part if writing the given number of bytes to it
+// would cross one of the configured limits, and creates a new part if there is
+// no current part.
+func (r *rotateWriteCloser) prepare(next int64) error {
+	if r.current != nil && r.full(next) {
+		if err := r.Rotate(); err != nil {
+			return err
+		}
+	}
+
+	if r.current != nil {
+		return nil
+	}
+
+	w, err := r.parts(r.index)
+	if err != nil {
+		return err
+	}
+
+	r.current = w
+	r.opened = time.Now()
+	r.written = 0
+	r.count = 0
+
+	return nil
+}
+
+func (r *rotateWriteCloser) full(next int64) bool {
+	if r.maxBytes > 0 {
+		// Unframed writes fill a part right up to the limit.
+		if next == 0 && r.written >= r.maxBytes {
+			return true
+		}
+
+		// Records are kept whole, so rotate if the next one would not fit.
+		if next > 0 && r.written > 0 && r.written+next > r.maxBytes {
+			return true
+		}
+	}
+
+	if r.records && r.maxRecords > 0 && r.count >= r.maxRecords {
+		return true
+	}
+
+	return r.interval > 0 && time.Since(r.opened) >= r.interval
+}
@@ -0,0 +1,147 @@
+package spipe_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+type memParts []*WriteCloser
+
+func (m *memParts) create(int) (io.WriteCloser, error) {
+	w := new(WriteCloser)
+	*m = append(*m, w)
+	return w, nil
+}
+
+func (m memParts) strings() []string {
+	out := make([]string, len(m))
+	for i, w := range m {
+		out[i] = string(w.WrittenBytes)
+	}
+	return out
+}
+
+func TestRotateWriteCloser_Write(t *testing.T) {
+	Convey("RotateWriteCloser.Write", t, func() {
+		Convey("byte limit", func() {
+			parts := new(memParts)
+			test := spipe.NewRotateWriteCloser(parts.create).MaxBytes(4)
+
+			n, err := test.Write([]byte("abcdefghij"))
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 10)
+			So(test.Close(), ShouldBeNil)
+			So(parts.strings(), ShouldResemble, []string{"abcd", "efgh", "ij"})
+			So(test.Part(), ShouldEqual, 3)
+
+			for _, p := range *parts {
+				So(p.CloseCalls, ShouldEqual, 1)
+			}
+		})
+
+		Convey("byte limit on record boundaries", func() {
+			parts := new(memParts)
+			test := spipe.NewRotateWriteCloser(parts.create).
+				MaxBytes(6).
+				Records(true)
+
+			_, err := test.Write([]byte("ab\ncd\nef\nlonger line\ngh"))
+
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+			So(parts.strings(), ShouldResemble, []string{
+				"ab\ncd\n",
+				"ef\n",
+				"longer line\n",
+				"gh",
+			})
+		})
+
+		Convey("record limit", func() {
+			parts := new(memParts)
+			test := spipe.NewRotateWriteCloser(parts.create).
+				MaxRecords(2).
+				Records(true).
+				Delimiter(';')
+
+			_, err := test.Write([]byte("a;b;c;d;e;"))
+
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+			So(parts.strings(), ShouldResemble, []string{"a;b;", "c;d;", "e;"})
+		})
+
+		Convey("interval", func() {
+			parts := new(memParts)
+			test := spipe.NewRotateWriteCloser(parts.create).
+				Interval(10 * time.Millisecond)
+
+			_, _ = test.Write([]byte("abc"))
+			_, _ = test.Write([]byte("def"))
+			time.Sleep(15 * time.Millisecond)
+			_, _ = test.Write([]byte("ghi"))
+
+			So(test.Close(), ShouldBeNil)
+			So(parts.strings(), ShouldResemble, []string{"abcdef", "ghi"})
+		})
+
+		Convey("rotate hook", func() {
+			var rotated []int
+			parts := new(memParts)
+			test := spipe.NewRotateWriteCloser(parts.create).
+				MaxBytes(2).
+				OnRotate(func(i int) error {
+					So((*parts)[i].CloseCalls, ShouldEqual, 1)
+					rotated = append(rotated, i)
+					return nil
+				})
+
+			_, _ = test.Write([]byte("abcde"))
+			So(rotated, ShouldResemble, []int{0, 1})
+
+			So(test.Close(), ShouldBeNil)
+			So(rotated, ShouldResemble, []int{0, 1, 2})
+		})
+
+		Convey("failing hook", func() {
+			parts := new(memParts)
+			test := spipe.NewRotateWriteCloser(parts.create).
+				MaxBytes(2).
+				OnRotate(func(int) error { return errors.New("hook") })
+
+			n, err := test.Write([]byte("abcde"))
+
+			So(err, ShouldResemble, errors.New("hook"))
+			So(n, ShouldEqual, 2)
+		})
+
+		Convey("failing factory", func() {
+			test := spipe.NewRotateWriteCloser(func(int) (io.WriteCloser, error) {
+				return nil, errors.New("factory")
+			})
+
+			_, err := test.Write([]byte("abc"))
+
+			So(err, ShouldResemble, errors.New("factory"))
+		})
+
+		Convey("short write", func() {
+			test := spipe.NewRotateWriteCloser(func(int) (io.WriteCloser, error) {
+				return &WriteCloser{WriteCounts: []int{1}}, nil
+			})
+
+			n, err := test.Write([]byte("abc"))
+
+			So(err, ShouldEqual, io.ErrShortWrite)
+			So(n, ShouldEqual, 1)
+		})
+	})
+}
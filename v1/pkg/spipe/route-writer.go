@@ -0,0 +1,233 @@
+package spipe
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+)
+
+// RoutePredicate decides whether a record should be sent to a route.
+//
+// The record passed to the predicate does not include its trailing delimiter
+// and is only valid for the duration of the call.
+type RoutePredicate func(record []byte) bool
+
+// RecordContains returns a RoutePredicate that matches records containing the
+// given substring.
+func RecordContains(sub string) RoutePredicate {
+	s := []byte(sub)
+	return func(record []byte) bool {
+		return bytes.Contains(record, s)
+	}
+}
+
+// RecordMatches returns a RoutePredicate that matches records accepted by the
+// given regular expression.
+func RecordMatches(re *regexp.Regexp) RoutePredicate {
+	return re.Match
+}
+
+// RouteStats holds the number of records and bytes written to a single route.
+type RouteStats struct {
+	// Name is the name the route was registered with.
+	Name string
+
+	// Records is the number of records written to the route.
+	Records int64
+
+	// Bytes is the number of bytes written to the route, including record
+	// delimiters.
+	Bytes int64
+}
+
+// FallbackRouteName is the name reported in RouteStats for the fallback route.
+const FallbackRouteName = "fallback"
+
+// RouteWriter defines an io.Writer implementation that splits its input into
+// records and sends each record to the outputs whose predicates accept it.
+//
+// Every record is written to the primary writer (if one was given).  Records
+// that are not accepted by any route are written to the fallback writer (if
+// one was set).
+//
+// Bytes following the last delimiter of a write are held until the record is
+// completed by a later write or until Flush is called.
+type RouteWriter interface {
+	io.Writer
+
+	// Route registers a new output that will receive every record accepted by
+	// the given predicate.
+	//
+	// Routes are evaluated in the order they are registered.
+	Route(name string, match RoutePredicate, out io.Writer) RouteWriter
+
+	// Fallback sets the output that will receive records that were not accepted
+	// by any route.
+	Fallback(out io.Writer) RouteWriter
+
+	// FirstMatch sets whether records should only be sent to the first route
+	// that accepts them, rather than to every route that accepts them.
+	FirstMatch(bool) RouteWriter
+
+	// Delimiter sets the byte used to separate records.  Defaults to
+	// DefaultDelimiter.
+	Delimiter(byte) RouteWriter
+
+	// IgnoreErrors sets whether or not the route writer should ignore errors
+	// returned from route and fallback writers.
+	IgnoreErrors(bool) RouteWriter
+
+	// Flush writes out any held partial record as if it were complete.
+	Flush() error
+
+	// Stats returns the record and byte counts for each route in the order the
+	// routes were registered, followed by the fallback route if one was set.
+	Stats() []RouteStats
+}
+
+// NewRouteWriter constructs a new RouteWriter instance that writes every record
+// to the given primary writer.
+//
+// The primary writer may be nil, in which case records are only written to the
+// matching routes.
+func NewRouteWriter(primary io.Writer) RouteWriter {
+	return &routeWriter{
+		primary: primary,
+		framer:  recordFramer{delim: DefaultDelimiter},
+	}
+}
+
+type route struct {
+	stats RouteStats
+	match RoutePredicate
+	out   io.Writer
+}
+
+type routeWriter struct {
+	primary    io.Writer
+	routes     []*route
+	fallback   *route
+	framer     recordFramer
+	firstMatch bool
+	ignoreErrs bool
+}
+
+// Write splits the given bytes into records and writes each complete record to
+// the primary writer and the matching routes.
+//
+// The returned byte count is the number of bytes consumed from p, which
+// includes bytes held back as part of an incomplete record.
+func (r *routeWriter) Write(p []byte) (int, error) {
+	return r.framer.frame(p, r.writeRecord)
+}
+
+func (r *routeWriter) Flush() error {
+	return r.framer.flush(r.writeRecord)
+}
+
+func (r *routeWriter) Route(name string, match RoutePredicate, out io.Writer) RouteWriter {
+	r.routes = append(r.routes, &route{
+		stats: RouteStats{Name: name},
+		match: match,
+		out:   out,
+	})
+	return r
+}
+
+func (r *routeWriter) Fallback(out io.Writer) RouteWriter {
+	r.fallback = &route{stats: RouteStats{Name: FallbackRouteName}, out: out}
+	return r
+}
+
+func (r *routeWriter) FirstMatch(b bool) RouteWriter {
+	r.firstMatch = b
+	return r
+}
+
+func (r *routeWriter) Delimiter(b byte) RouteWriter {
+	r.framer.delim = b
+	return r
+}
+
+func (r *routeWriter) IgnoreErrors(b bool) RouteWriter {
+	r.ignoreErrs = b
+	return r
+}
+
+func (r *routeWriter) Stats() []RouteStats {
+	out := make([]RouteStats, 0, len(r.routes)+1)
+
+	for _, rt := range r.routes {
+		out = append(out, rt.stats)
+	}
+
+	if r.fallback != nil {
+		out = append(out, r.fallback.stats)
+	}
+
+	return out
+}
+
+func (r *routeWriter) writeRecord(rec []byte) error {
+	if r.primary != nil {
+		if err := writeRecordTo(r.primary, rec); err != nil {
+			return err
+		}
+	}
+
+	body := trimDelimiter(rec, r.framer.delim)
+	matched := false
+
+	for _, rt := range r.routes {
+		if !rt.match(body) {
+			continue
+		}
+
+		matched = true
+
+		if err := r.writeRoute(rt, rec); err != nil {
+			return err
+		}
+
+		if r.firstMatch {
+			break
+		}
+	}
+
+	if !matched && r.fallback != nil {
+		return r.writeRoute(r.fallback, rec)
+	}
+
+	return nil
+}
+
+func (r *routeWriter) writeRoute(rt *route, rec []byte) error {
+	if err := writeRecordTo(rt.out, rec); err != nil {
+		if r.ignoreErrs {
+			return nil
+		}
+
+		return err
+	}
+
+	rt.stats.Records++
+	rt.stats.Bytes += int64(len(rec))
+
+	return nil
+}
+
+// writeRecordTo writes the given record to the given writer, translating short
+// writes into io.ErrShortWrite.
+func writeRecordTo(w io.Writer, rec []byte) error {
+	n, err := w.Write(rec)
+
+	if err != nil {
+		return err
+	}
+
+	if n < len(rec) {
+		return io.ErrShortWrite
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package spipe_test
+
+import (
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestRouteWriter_Write(t *testing.T) {
+	Convey("RouteWriter.Write", t, func() {
+		input := "info: started\nERROR: boom\naudit: login\naudit ERROR: denied\n"
+
+		Convey("fan out to every match", func() {
+			primary := new(strings.Builder)
+			alerts := new(strings.Builder)
+			audit := new(strings.Builder)
+			other := new(strings.Builder)
+
+			test := spipe.NewRouteWriter(primary).
+				Route("alerts", spipe.RecordContains("ERROR"), alerts).
+				Route("audit", spipe.RecordMatches(regexp.MustCompile("^audit")), audit).
+				Fallback(other)
+
+			n, err := test.Write([]byte(input))
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, len(input))
+			So(primary.String(), ShouldEqual, input)
+			So(alerts.String(), ShouldEqual, "ERROR: boom\naudit ERROR: denied\n")
+			So(audit.String(), ShouldEqual, "audit: login\naudit ERROR: denied\n")
+			So(other.String(), ShouldEqual, "info: started\n")
+			So(test.Stats(), ShouldResemble, []spipe.RouteStats{
+				{Name: "alerts", Records: 2, Bytes: 32},
+				{Name: "audit", Records: 2, Bytes: 33},
+				{Name: spipe.FallbackRouteName, Records: 1, Bytes: 14},
+			})
+		})
+
+		Convey("first match only", func() {
+			alerts := new(strings.Builder)
+			audit := new(strings.Builder)
+
+			test := spipe.NewRouteWriter(nil).
+				Route("alerts", spipe.RecordContains("ERROR"), alerts).
+				Route("audit", spipe.RecordMatches(regexp.MustCompile("^audit")), audit).
+				FirstMatch(true)
+
+			_, err := test.Write([]byte(input))
+
+			So(err, ShouldBeNil)
+			So(alerts.String(), ShouldEqual, "ERROR: boom\naudit ERROR: denied\n")
+			So(audit.String(), ShouldEqual, "audit: login\n")
+		})
+
+		Convey("split records", func() {
+			primary := new(strings.Builder)
+			alerts := new(strings.Builder)
+
+			test := spipe.NewRouteWriter(primary).
+				Route("alerts", spipe.RecordContains("ERROR"), alerts).
+				Delimiter(';')
+
+			_, err := test.Write([]byte("ok;ER"))
+			So(err, ShouldBeNil)
+			So(primary.String(), ShouldEqual, "ok;")
+			So(alerts.String(), ShouldEqual, "")
+
+			_, err = test.Write([]byte("ROR;tail"))
+			So(err, ShouldBeNil)
+			So(primary.String(), ShouldEqual, "ok;ERROR;")
+			So(alerts.String(), ShouldEqual, "ERROR;")
+
+			So(test.Flush(), ShouldBeNil)
+			So(primary.String(), ShouldEqual, "ok;ERROR;tail")
+		})
+
+		Convey("failing route", func() {
+			Convey("without ignore", func() {
+				bad := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+
+				test := spipe.NewRouteWriter(nil).
+					Route("bad", spipe.RecordContains("ERROR"), bad)
+
+				n, err := test.Write([]byte(input))
+
+				So(err, ShouldResemble, errors.New("hiya!"))
+				So(n, ShouldEqual, 26)
+				So(test.Stats()[0].Records, ShouldEqual, 0)
+			})
+
+			Convey("with ignore", func() {
+				bad := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+
+				test := spipe.NewRouteWriter(nil).
+					Route("bad", spipe.RecordContains("ERROR"), bad).
+					IgnoreErrors(true)
+
+				n, err := test.Write([]byte(input))
+
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, len(input))
+				So(test.Stats()[0].Records, ShouldEqual, 1)
+			})
+		})
+
+		Convey("short write on primary", func() {
+			primary := &WriteCloser{WriteCounts: []int{1}}
+
+			_, err := spipe.NewRouteWriter(primary).Write([]byte("abc\n"))
+
+			So(err, ShouldEqual, io.ErrShortWrite)
+		})
+	})
+}
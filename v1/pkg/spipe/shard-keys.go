@@ -0,0 +1,96 @@
+package spipe
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// ErrNoKey is returned by a KeyExtractor when a record does not contain a key.
+var ErrNoKey = errors.New("spipe: record has no key")
+
+// KeyExtractor returns the partitioning key for the given record.
+//
+// The record passed to the extractor does not include its trailing delimiter
+// and is only valid for the duration of the call.
+type KeyExtractor func(record []byte) ([]byte, error)
+
+// JSONFieldKey returns a KeyExtractor that parses each record as a JSON object
+// and uses the value of the given field as the key.
+//
+// Nested fields may be selected with a dot separated path such as "user.id".
+// String values are used without their quotes, any other value is used as its
+// compact JSON encoding.
+func JSONFieldKey(field string) KeyExtractor {
+	path := strings.Split(field, ".")
+
+	return func(record []byte) ([]byte, error) {
+		raw := json.RawMessage(record)
+
+		for _, name := range path {
+			var obj map[string]json.RawMessage
+
+			if err := json.Unmarshal(raw, &obj); err != nil {
+				return nil, err
+			}
+
+			var ok bool
+			if raw, ok = obj[name]; !ok {
+				return nil, ErrNoKey
+			}
+		}
+
+		var str string
+		if err := json.Unmarshal(raw, &str); err == nil {
+			return []byte(str), nil
+		}
+
+		buf := new(bytes.Buffer)
+		if err := json.Compact(buf, raw); err != nil {
+			return nil, err
+		}
+
+		return buf.Bytes(), nil
+	}
+}
+
+// CSVColumnKey returns a KeyExtractor that parses each record as a single CSV
+// row separated by the given rune and uses the value of the given zero based
+// column as the key.
+func CSVColumnKey(column int, comma rune) KeyExtractor {
+	return func(record []byte) ([]byte, error) {
+		rd := csv.NewReader(bytes.NewReader(record))
+		rd.Comma = comma
+		rd.FieldsPerRecord = -1
+
+		row, err := rd.Read()
+		if err != nil {
+			return nil, err
+		}
+
+		if column < 0 || column >= len(row) {
+			return nil, ErrNoKey
+		}
+
+		return []byte(row[column]), nil
+	}
+}
+
+// RegexKey returns a KeyExtractor that uses the given capture group of the
+// first match of the given regular expression as the key.
+//
+// Group 0 uses the full match.
+func RegexKey(re *regexp.Regexp, group int) KeyExtractor {
+	return func(record []byte) ([]byte, error) {
+		match := re.FindSubmatchIndex(record)
+
+		if match == nil || group < 0 || 2*group+1 >= len(match) || match[2*group] < 0 {
+			return nil, ErrNoKey
+		}
+
+		return record[match[2*group]:match[2*group+1]], nil
+	}
+}
@@ -0,0 +1,170 @@
+package spipe
+
+import (
+	"errors"
+	"hash/fnv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// ErrNoShards is returned when a ShardWriter is written to before any output
+// shards have been added.
+var ErrNoShards = errors.New("spipe: no shards available")
+
+// ShardWriter defines an io.Writer implementation that splits its input into
+// records and partitions those records across multiple outputs by key.
+//
+// Keys are mapped to outputs using a consistent hash, so every record with a
+// given key is written to the same output.  When a new output is added, only
+// the keys that now map to the new output move, all other keys stay where they
+// were.
+//
+// Bytes following the last delimiter of a write are held until the record is
+// completed by a later write or until Flush is called.
+type ShardWriter interface {
+	io.Writer
+
+	// AddShard adds a new output to the shard set.
+	AddShard(out io.Writer) ShardWriter
+
+	// Delimiter sets the byte used to separate records.  Defaults to
+	// DefaultDelimiter.
+	Delimiter(byte) ShardWriter
+
+	// Flush writes out any held partial record as if it were complete.
+	Flush() error
+
+	// Shard returns the index of the output the given key maps to, or -1 if
+	// no outputs have been added.
+	Shard(key []byte) int
+}
+
+// NewShardWriter constructs a new ShardWriter instance that uses the given
+// KeyExtractor to partition records across the given outputs.
+func NewShardWriter(key KeyExtractor, outputs ...io.Writer) ShardWriter {
+	out := &shardWriter{
+		key:    key,
+		framer: recordFramer{delim: DefaultDelimiter},
+	}
+
+	for _, w := range outputs {
+		out.AddShard(w)
+	}
+
+	return out
+}
+
+type shardWriter struct {
+	key     KeyExtractor
+	outputs []io.Writer
+	ring    hashRing
+	framer  recordFramer
+}
+
+// Write splits the given bytes into records and writes each complete record to
+// the output its key maps to.
+//
+// The returned byte count is the number of bytes consumed from p, which
+// includes bytes held back as part of an incomplete record.
+func (s *shardWriter) Write(p []byte) (int, error) {
+	return s.framer.frame(p, s.writeRecord)
+}
+
+func (s *shardWriter) Flush() error {
+	return s.framer.flush(s.writeRecord)
+}
+
+func (s *shardWriter) AddShard(out io.Writer) ShardWriter {
+	s.ring.add(len(s.outputs))
+	s.outputs = append(s.outputs, out)
+	return s
+}
+
+func (s *shardWriter) Delimiter(b byte) ShardWriter {
+	s.framer.delim = b
+	return s
+}
+
+func (s *shardWriter) Shard(key []byte) int {
+	return s.ring.locate(key)
+}
+
+func (s *shardWriter) writeRecord(rec []byte) error {
+	if len(s.outputs) == 0 {
+		return ErrNoShards
+	}
+
+	key, err := s.key(trimDelimiter(rec, s.framer.delim))
+	if err != nil {
+		return err
+	}
+
+	return writeRecordTo(s.outputs[s.ring.locate(key)], rec)
+}
+
+// ringReplicas is the number of points each shard is given on the hash ring.
+//
+// More points give a more even spread of keys across the shards.
+const ringReplicas = 128
+
+type ringPoint struct {
+	hash  uint64
+	shard int
+}
+
+// hashRing is a consistent hash ring mapping keys to shard indices.
+type hashRing struct {
+	points []ringPoint
+}
+
+func (h *hashRing) add(shard int) {
+	buf := make([]byte, 0, 32)
+
+	for i := 0; i < ringReplicas; i++ {
+		buf = strconv.AppendInt(buf[:0], int64(shard), 10)
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, int64(i), 10)
+
+		h.points = append(h.points, ringPoint{hashKey(buf), shard})
+	}
+
+	sort.Slice(h.points, func(i, j int) bool {
+		return h.points[i].hash < h.points[j].hash
+	})
+}
+
+func (h *hashRing) locate(key []byte) int {
+	if len(h.points) == 0 {
+		return -1
+	}
+
+	sum := hashKey(key)
+	i := sort.Search(len(h.points), func(i int) bool {
+		return h.points[i].hash >= sum
+	})
+
+	// Wrap around to the start of the ring.
+	if i == len(h.points) {
+		i = 0
+	}
+
+	return h.points[i].shard
+}
+
+// hashKey returns a well mixed 64 bit hash of the given key.
+func hashKey(key []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(key)
+	x := h.Sum64()
+
+	// FNV alone clusters badly on short, similar keys, so finish with the
+	// splitmix64 mixing step.
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+
+	return x
+}
@@ -0,0 +1,137 @@
+package spipe_test
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestShardWriter_Write(t *testing.T) {
+	Convey("ShardWriter.Write", t, func() {
+		Convey("records with the same key share a shard", func() {
+			shards := []*strings.Builder{
+				new(strings.Builder),
+				new(strings.Builder),
+				new(strings.Builder),
+			}
+
+			test := spipe.NewShardWriter(spipe.CSVColumnKey(0, ','),
+				shards[0], shards[1], shards[2])
+
+			input := new(strings.Builder)
+			for i := 0; i < 300; i++ {
+				fmt.Fprintf(input, "user%d,%d\n", i%30, i)
+			}
+
+			n, err := test.Write([]byte(input.String()))
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, input.Len())
+
+			for i := 0; i < 30; i++ {
+				key := fmt.Sprintf("user%d", i)
+				idx := test.Shard([]byte(key))
+
+				for j, s := range shards {
+					if j == idx {
+						So(strings.Count(s.String(), key+","), ShouldEqual, 10)
+					} else {
+						So(s.String(), ShouldNotContainSubstring, key+",")
+					}
+				}
+			}
+		})
+
+		Convey("adding a shard only moves keys to the new shard", func() {
+			test := spipe.NewShardWriter(spipe.CSVColumnKey(0, ','),
+				new(strings.Builder), new(strings.Builder))
+
+			before := make([]int, 1000)
+			for i := range before {
+				before[i] = test.Shard([]byte(fmt.Sprint(i)))
+			}
+
+			test.AddShard(new(strings.Builder))
+
+			moved := 0
+			for i := range before {
+				after := test.Shard([]byte(fmt.Sprint(i)))
+
+				if after != before[i] {
+					So(after, ShouldEqual, 2)
+					moved++
+				}
+			}
+
+			So(moved, ShouldBeGreaterThan, 0)
+			So(moved, ShouldBeLessThan, 600)
+		})
+
+		Convey("without shards", func() {
+			_, err := spipe.NewShardWriter(spipe.CSVColumnKey(0, ',')).
+				Write([]byte("a,b\n"))
+
+			So(err, ShouldEqual, spipe.ErrNoShards)
+		})
+
+		Convey("partial records", func() {
+			out := new(strings.Builder)
+			test := spipe.NewShardWriter(spipe.CSVColumnKey(0, ','), out)
+
+			_, err := test.Write([]byte("a,1\nb,"))
+			So(err, ShouldBeNil)
+			So(out.String(), ShouldEqual, "a,1\n")
+
+			So(test.Flush(), ShouldBeNil)
+			So(out.String(), ShouldEqual, "a,1\nb,")
+		})
+	})
+}
+
+func TestKeyExtractors(t *testing.T) {
+	Convey("KeyExtractor", t, func() {
+		Convey("JSONFieldKey", func() {
+			key, err := spipe.JSONFieldKey("user.id")([]byte(`{"user": {"id": "abc"}}`))
+			So(err, ShouldBeNil)
+			So(string(key), ShouldEqual, "abc")
+
+			key, err = spipe.JSONFieldKey("n")([]byte(`{"n": { "a" : 1 }}`))
+			So(err, ShouldBeNil)
+			So(string(key), ShouldEqual, `{"a":1}`)
+
+			_, err = spipe.JSONFieldKey("missing")([]byte(`{"n": 1}`))
+			So(err, ShouldEqual, spipe.ErrNoKey)
+
+			_, err = spipe.JSONFieldKey("n")([]byte(`not json`))
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("CSVColumnKey", func() {
+			key, err := spipe.CSVColumnKey(1, ';')([]byte(`a;"b;c";d`))
+			So(err, ShouldBeNil)
+			So(string(key), ShouldEqual, "b;c")
+
+			_, err = spipe.CSVColumnKey(5, ';')([]byte(`a;b`))
+			So(err, ShouldEqual, spipe.ErrNoKey)
+		})
+
+		Convey("RegexKey", func() {
+			re := regexp.MustCompile(`id=(\w+)`)
+
+			key, err := spipe.RegexKey(re, 1)([]byte("GET /x id=42 ok"))
+			So(err, ShouldBeNil)
+			So(string(key), ShouldEqual, "42")
+
+			_, err = spipe.RegexKey(re, 1)([]byte("GET /x"))
+			So(err, ShouldEqual, spipe.ErrNoKey)
+
+			_, err = spipe.RegexKey(re, 2)([]byte("id=42"))
+			So(err, ShouldEqual, spipe.ErrNoKey)
+		})
+	})
+}
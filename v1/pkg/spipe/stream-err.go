@@ -0,0 +1,112 @@
+package spipe
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Role identifies the part a stream plays in a spipe reader or writer.
+type Role int
+
+const (
+	// RolePrimary identifies the primary output of a split writer.
+	RolePrimary Role = iota
+
+	// RoleSecondary identifies a secondary output of a split writer.
+	RoleSecondary
+
+	// RoleInput identifies an input of a multi-reader.
+	RoleInput
+)
+
+func (r Role) String() string {
+	switch r {
+	case RolePrimary:
+		return "primary"
+	case RoleSecondary:
+		return "secondary"
+	case RoleInput:
+		return "input"
+	}
+
+	return "Role(" + strconv.Itoa(int(r)) + ")"
+}
+
+// Operation names recorded in StreamError values.
+const (
+	OpWrite    = "Write"
+	OpClose    = "Close"
+	OpRead     = "Read"
+	OpPopInput = "popInput"
+	OpFlush    = "Flush"
+	OpSync     = "Sync"
+	OpVerify   = "Verify"
+)
+
+// StreamError wraps an error returned by one of the streams underlying a spipe
+// reader or writer, identifying which stream failed and what it was doing.
+type StreamError struct {
+	// Role is the part the failing stream plays.
+	Role Role
+
+	// Index is the position of the failing stream in the list of streams the
+	// reader or writer was constructed with.  For split writers the primary
+	// output is at index 0 and the secondary outputs start at index 1.
+	Index int
+
+	// Name is the name given to the failing stream, if any.
+	Name string
+
+	// Op is the operation that failed.
+	Op string
+
+	// Err is the error returned by the failing stream.
+	Err error
+}
+
+func (s *StreamError) Error() string {
+	out := strings.Builder{}
+
+	out.WriteString(s.Op)
+	out.WriteByte(' ')
+	out.WriteString(s.Role.String())
+	out.WriteByte(' ')
+	out.WriteString(strconv.Itoa(s.Index))
+
+	if s.Name != "" {
+		out.WriteString(" (")
+		out.WriteString(s.Name)
+		out.WriteByte(')')
+	}
+
+	out.WriteString(": ")
+	out.WriteString(s.Err.Error())
+
+	return out.String()
+}
+
+// Unwrap returns the error returned by the failing stream.
+func (s *StreamError) Unwrap() error {
+	return s.Err
+}
+
+// newStreamError wraps the given error in a StreamError, looking up the
+// stream's name from the given list of names.
+func newStreamError(op string, role Role, index int, names []string, err error) error {
+	out := &StreamError{Role: role, Index: index, Op: op, Err: err}
+
+	if index < len(names) {
+		out.Name = names[index]
+	}
+
+	return out
+}
+
+// outputRole returns the role of the split writer output at the given index.
+func outputRole(index int) Role {
+	if index == 0 {
+		return RolePrimary
+	}
+
+	return RoleSecondary
+}
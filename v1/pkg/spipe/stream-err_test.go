@@ -0,0 +1,90 @@
+package spipe_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+func TestStreamError_Error(t *testing.T) {
+	Convey("StreamError.Error", t, func() {
+		Convey("without a name", func() {
+			err := &spipe.StreamError{
+				Role:  spipe.RoleSecondary,
+				Index: 2,
+				Op:    spipe.OpWrite,
+				Err:   errors.New("hiya!"),
+			}
+
+			So(err.Error(), ShouldEqual, "Write secondary 2: hiya!")
+		})
+
+		Convey("with a name", func() {
+			err := &spipe.StreamError{
+				Role:  spipe.RoleInput,
+				Index: 0,
+				Name:  "access.log",
+				Op:    spipe.OpRead,
+				Err:   errors.New("hiya!"),
+			}
+
+			So(err.Error(), ShouldEqual, "Read input 0 (access.log): hiya!")
+		})
+
+		Convey("unwrap", func() {
+			cause := errors.New("hiya!")
+			err := &spipe.StreamError{Err: cause}
+
+			So(errors.Is(err, cause), ShouldBeTrue)
+		})
+	})
+}
+
+func TestStreamError_Names(t *testing.T) {
+	Convey("StreamError names", t, func() {
+		Convey("SplitWriter", func() {
+			bad := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+
+			_, err := spipe.NewSplitWriter(new(strings.Builder), bad).
+				Names("main", "archive").
+				Write([]byte("hello"))
+
+			So(err.Error(), ShouldEqual, "Write secondary 1 (archive): hiya!")
+		})
+
+		Convey("SplitWriteCloser", func() {
+			bad := &WriteCloser{CloseErrors: []error{errors.New("hi")}}
+
+			err := spipe.NewSplitWriteCloser(bad, new(WriteCloser)).
+				Names("main").
+				Close()
+
+			So(err.Error(), ShouldEqual, "Close primary 0 (main): hi")
+		})
+
+		Convey("MultiReader", func() {
+			bad := &ReadCloser{ReadErrors: []error{errors.New("hola")}}
+
+			_, err := spipe.NewMultiReader(strings.NewReader("abc"), bad).
+				Names("first", "second").
+				Read(make([]byte, 10))
+
+			So(err.Error(), ShouldEqual, "Read input 1 (second): hola")
+		})
+
+		Convey("MultiReadCloser", func() {
+			bad := &ReadCloser{CloseErrors: []error{errors.New("hi")}}
+
+			err := spipe.NewMultiReadCloser(new(ReadCloser), bad).
+				Names("first", "second").
+				Close()
+
+			So(err.Error(), ShouldEqual, "Close input 1 (second): hi")
+		})
+	})
+}
@@ -0,0 +1,201 @@
+package spipe
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+)
+
+// Transformer wraps an output in a streaming transformation.
+//
+// Bytes written to the returned io.WriteCloser are transformed and written on
+// to the given writer.  Closing the returned io.WriteCloser must write out any
+// data it is holding, but must not close the given writer.
+type Transformer interface {
+	Transform(out io.Writer) io.WriteCloser
+}
+
+// TransformerFunc adapts a function to the Transformer interface.
+//
+// Constructors from the standard library that wrap a writer in a WriteCloser,
+// such as base64.NewEncoder, can be adapted with a closure:
+//
+//	spipe.TransformerFunc(func(w io.Writer) io.WriteCloser {
+//		return base64.NewEncoder(base64.StdEncoding, w)
+//	})
+type TransformerFunc func(out io.Writer) io.WriteCloser
+
+// Transform calls f(out).
+func (f TransformerFunc) Transform(out io.Writer) io.WriteCloser {
+	return f(out)
+}
+
+// GzipTransform returns a Transformer that gzip compresses the stream at the
+// given compression level.  An error is returned if the level is not valid, see
+// gzip.NewWriterLevel.
+func GzipTransform(level int) (Transformer, error) {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+
+	return TransformerFunc(func(out io.Writer) io.WriteCloser {
+		w, _ := gzip.NewWriterLevel(out, level)
+		return w
+	}), nil
+}
+
+// ZlibTransform returns a Transformer that zlib compresses the stream at the
+// given compression level.  An error is returned if the level is not valid, see
+// zlib.NewWriterLevel.
+func ZlibTransform(level int) (Transformer, error) {
+	if _, err := zlib.NewWriterLevel(nil, level); err != nil {
+		return nil, err
+	}
+
+	return TransformerFunc(func(out io.Writer) io.WriteCloser {
+		w, _ := zlib.NewWriterLevel(out, level)
+		return w
+	}), nil
+}
+
+// LineFilter returns a Transformer that only passes on the lines of the stream
+// that the given predicate matches.
+//
+// The predicate is given each line without its trailing newline.  A final line
+// with no trailing newline is held until Close.
+func LineFilter(keep RoutePredicate) Transformer {
+	return TransformerFunc(func(out io.Writer) io.WriteCloser {
+		return &lineTransformer{
+			out:    out,
+			framer: recordFramer{delim: DefaultDelimiter},
+			fn: func(line []byte) []byte {
+				if keep(line) {
+					return line
+				}
+
+				return nil
+			},
+		}
+	})
+}
+
+// LineMap returns a Transformer that replaces each line of the stream with the
+// result of the given function.  Returning nil drops the line.
+//
+// The function is given each line without its trailing newline, which is
+// added back to the returned line.  The given slice is only valid for the
+// duration of the call.  A final line with no trailing newline is held until
+// Close.
+func LineMap(fn func(line []byte) []byte) Transformer {
+	return TransformerFunc(func(out io.Writer) io.WriteCloser {
+		return &lineTransformer{
+			out:    out,
+			framer: recordFramer{delim: DefaultDelimiter},
+			fn:     fn,
+		}
+	})
+}
+
+type lineTransformer struct {
+	out    io.Writer
+	framer recordFramer
+	fn     func([]byte) []byte
+	buf    []byte
+}
+
+func (l *lineTransformer) Write(p []byte) (int, error) {
+	return l.framer.frame(p, l.emit)
+}
+
+func (l *lineTransformer) Close() error {
+	return l.framer.flush(l.emit)
+}
+
+func (l *lineTransformer) emit(rec []byte) error {
+	line := trimDelimiter(rec, l.framer.delim)
+	out := l.fn(line)
+
+	if out == nil {
+		return nil
+	}
+
+	l.buf = append(l.buf[:0], out...)
+
+	if len(line) < len(rec) {
+		l.buf = append(l.buf, l.framer.delim)
+	}
+
+	return writeRecordTo(l.out, l.buf)
+}
+
+// transformChain is an output wrapped in a chain of transformers.  Writes go
+// to the first transformer, and each transformer writes to the next, with the
+// last writing to the output.
+type transformChain struct {
+	// layers holds the transformers' writers in the order data passes through
+	// them.
+	layers []io.WriteCloser
+	out    io.WriteCloser
+}
+
+func newTransformChain(out io.WriteCloser, chain []Transformer) io.WriteCloser {
+	if len(chain) == 0 {
+		return out
+	}
+
+	layers := make([]io.WriteCloser, len(chain))
+
+	var next io.Writer = out
+	for i := len(chain) - 1; i >= 0; i-- {
+		layers[i] = chain[i].Transform(next)
+		next = layers[i]
+	}
+
+	return &transformChain{layers: layers, out: out}
+}
+
+func (t *transformChain) Write(p []byte) (int, error) {
+	return t.layers[0].Write(p)
+}
+
+// Flush flushes every layer that supports it, in the order data passes through
+// them, and then the output.
+func (t *transformChain) Flush() error {
+	errs := NewMultiErrorBuilder()
+
+	for _, w := range t.layers {
+		errs.Add(flushWriter(w))
+	}
+
+	errs.Add(flushWriter(t.out))
+
+	return errs.Build()
+}
+
+// Sync flushes every layer that supports it, and the output, so the data
+// written so far reaches the output, and then calls Sync on the output if it
+// supports it.
+func (t *transformChain) Sync() error {
+	errs := NewMultiErrorBuilder().Add(t.Flush())
+
+	if s, ok := t.out.(syncer); ok {
+		errs.Add(s.Sync())
+	}
+
+	return errs.Build()
+}
+
+// Close closes every layer in the order data passes through them, so each
+// layer's remaining data is written to the next before that is closed, and
+// then closes the output.
+func (t *transformChain) Close() error {
+	errs := NewMultiErrorBuilder()
+
+	for _, w := range t.layers {
+		errs.Add(w.Close())
+	}
+
+	errs.Add(t.out.Close())
+
+	return errs.Build()
+}
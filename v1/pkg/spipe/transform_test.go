@@ -0,0 +1,214 @@
+package spipe_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/base64"
+	"errors"
+	"io"
+	"io/ioutil"
+	"regexp"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// closeOrderWC records the bytes it holds when it is closed.
+type closeOrderWC struct {
+	bytes.Buffer
+	atClose []byte
+}
+
+func (c *closeOrderWC) Close() error {
+	c.atClose = append([]byte(nil), c.Bytes()...)
+	return nil
+}
+
+func TestSplitWriteCloser_Transform(t *testing.T) {
+	Convey("SplitWriteCloser.Transform", t, func() {
+		input := "GET /a 200\nPOST /b token=abc123 500\nGET /c 404"
+
+		Convey("gzip", func() {
+			primary, archive := new(WriteCloser), new(closeOrderWC)
+			gz, err := spipe.GzipTransform(gzip.BestSpeed)
+			So(err, ShouldBeNil)
+
+			test := spipe.NewSplitWriteCloser(primary, archive).Transform(1, gz)
+
+			_, err = test.Write([]byte(input))
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+
+			So(string(primary.WrittenBytes), ShouldEqual, input)
+
+			// The gzip stream was complete before the output was closed.
+			r, err := gzip.NewReader(bytes.NewReader(archive.atClose))
+			So(err, ShouldBeNil)
+			out, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, input)
+		})
+
+		Convey("primary and unknown positions are ignored", func() {
+			primary, archive := new(WriteCloser), new(WriteCloser)
+			gz, _ := spipe.GzipTransform(gzip.BestSpeed)
+
+			test := spipe.NewSplitWriteCloser(primary, archive).
+				Transform(0, gz).
+				Transform(2, gz).
+				Transform(-1, gz)
+
+			_, err := test.Write([]byte(input))
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+
+			So(string(primary.WrittenBytes), ShouldEqual, input)
+			So(string(archive.WrittenBytes), ShouldEqual, input)
+		})
+
+		Convey("zlib", func() {
+			archive := new(closeOrderWC)
+			zl, err := spipe.ZlibTransform(zlib.DefaultCompression)
+			So(err, ShouldBeNil)
+
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), archive).Transform(1, zl)
+
+			_, _ = test.WriteString(input)
+			So(test.Close(), ShouldBeNil)
+
+			r, err := zlib.NewReader(bytes.NewReader(archive.atClose))
+			So(err, ShouldBeNil)
+			out, _ := ioutil.ReadAll(r)
+			So(string(out), ShouldEqual, input)
+		})
+
+		Convey("invalid levels", func() {
+			_, err := spipe.GzipTransform(42)
+			So(err, ShouldNotBeNil)
+
+			_, err = spipe.ZlibTransform(42)
+			So(err, ShouldNotBeNil)
+		})
+
+		Convey("line filter", func() {
+			out := new(closeOrderWC)
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), out).
+				Transform(1, spipe.LineFilter(spipe.RecordContains("GET")))
+
+			// Write in pieces that split lines.
+			for i := 0; i < len(input); i += 7 {
+				end := i + 7
+				if end > len(input) {
+					end = len(input)
+				}
+				_, err := test.Write([]byte(input[i:end]))
+				So(err, ShouldBeNil)
+			}
+
+			So(out.String(), ShouldEqual, "GET /a 200\n")
+			So(test.Close(), ShouldBeNil)
+			So(string(out.atClose), ShouldEqual, "GET /a 200\nGET /c 404")
+		})
+
+		Convey("line map", func() {
+			token := regexp.MustCompile(`token=\w+`)
+			out := new(closeOrderWC)
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), out).
+				Transform(1, spipe.LineMap(func(line []byte) []byte {
+					if bytes.HasPrefix(line, []byte("GET /c")) {
+						return nil
+					}
+
+					return token.ReplaceAll(line, []byte("token=REDACTED"))
+				}))
+
+			_, _ = test.Write([]byte(input))
+			So(test.Close(), ShouldBeNil)
+			So(out.String(), ShouldEqual, "GET /a 200\nPOST /b token=REDACTED 500\n")
+		})
+
+		Convey("chains", func() {
+			gz, _ := spipe.GzipTransform(gzip.DefaultCompression)
+			b64 := spipe.TransformerFunc(func(w io.Writer) io.WriteCloser {
+				return base64.NewEncoder(base64.StdEncoding, w)
+			})
+
+			out := new(closeOrderWC)
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), out).
+				Transform(1, spipe.LineFilter(spipe.RecordContains("GET")), gz, b64)
+
+			_, _ = test.Write([]byte(input))
+			So(test.Close(), ShouldBeNil)
+
+			r, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(out.atClose)))
+			So(err, ShouldBeNil)
+			plain, err := ioutil.ReadAll(r)
+			So(err, ShouldBeNil)
+			So(string(plain), ShouldEqual, "GET /a 200\nGET /c 404")
+		})
+
+		Convey("flush", func() {
+			gz, _ := spipe.GzipTransform(gzip.DefaultCompression)
+			out := new(closeOrderWC)
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), out).Transform(1, gz)
+
+			_, _ = test.Write([]byte(input))
+			So(test.Flush(), ShouldBeNil)
+
+			r, err := gzip.NewReader(bytes.NewReader(out.Bytes()))
+			So(err, ShouldBeNil)
+			buf := make([]byte, len(input))
+			n, _ := io.ReadFull(r, buf)
+			So(string(buf[:n]), ShouldEqual, input)
+		})
+
+		Convey("sync", func() {
+			gz, _ := spipe.GzipTransform(gzip.DefaultCompression)
+			f := tempFile("spipe-transform")
+			defer f.Close()
+
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), f).Transform(1, gz)
+
+			_, _ = test.Write([]byte(input))
+			So(test.Sync(), ShouldBeNil)
+
+			synced, err := ioutil.ReadFile(f.Name())
+			So(err, ShouldBeNil)
+
+			r, err := gzip.NewReader(bytes.NewReader(synced))
+			So(err, ShouldBeNil)
+			buf := make([]byte, len(input))
+			n, _ := io.ReadFull(r, buf)
+			So(string(buf[:n]), ShouldEqual, input)
+
+			Convey("reaches the output", func() {
+				out := new(flushSyncer)
+				test := spipe.NewSplitWriteCloser(new(WriteCloser), out).Transform(1, gz)
+
+				So(test.Sync(), ShouldBeNil)
+				So(out.syncs, ShouldEqual, 1)
+			})
+		})
+
+		Convey("close errors", func() {
+			bad := spipe.TransformerFunc(func(w io.Writer) io.WriteCloser {
+				return &WriteCloser{CloseErrors: []error{errors.New("hiya!")}}
+			})
+
+			out := new(WriteCloser)
+			test := spipe.NewSplitWriteCloser(new(WriteCloser), out).Transform(1, bad)
+
+			err := test.Close()
+			se := new(spipe.StreamError)
+
+			So(errors.As(err, &se), ShouldBeTrue)
+			So(se.Op, ShouldEqual, spipe.OpClose)
+			So(se.Index, ShouldEqual, 1)
+			So(out.CloseCalls, ShouldEqual, 1)
+		})
+	})
+}
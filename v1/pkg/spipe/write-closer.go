@@ -1,19 +1,114 @@
 package spipe
 
-import "io"
+import (
+	"context"
+	"hash"
+	"io"
+	"os"
+	"time"
+)
 
 // SplitWriteCloser defines an io.WriteCloser implementation that writes to and
 // can close multiple outputs.
 type SplitWriteCloser interface {
 	io.WriteCloser
+	io.StringWriter
+	io.ByteWriter
 
 	// IgnoreErrors sets whether or not the split writer should ignore errors
 	// returned from secondary writers.
 	IgnoreErrors(bool) SplitWriteCloser
+
+	// Names sets the names used to identify the outputs in returned
+	// StreamError values.  Names are given in the same order as the writers
+	// were given to the constructor, primary first.
+	Names(...string) SplitWriteCloser
+
+	// Flush calls Flush on every output that implements it, such as
+	// *bufio.Writer or *gzip.Writer.
+	//
+	// Errors are collected into a MultiError.  Errors from secondary writers
+	// are dropped if IgnoreErrors is set.
+	Flush() error
+
+	// Sync calls Sync on every output that implements it, such as *os.File.
+	//
+	// Errors are collected into a MultiError.  Errors from secondary writers
+	// are dropped if IgnoreErrors is set.
+	Sync() error
+
+	// Instrument enables the collection of Metrics for the outputs, passing an
+	// Observation for every write to each of the given observers.
+	Instrument(...Observer) SplitWriteCloser
+
+	// Metrics returns a snapshot of the counters collected for the outputs,
+	// primary first.  The snapshot is empty if Instrument has not been called.
+	Metrics() Metrics
+
+	// Limit caps the rate at which bytes are written to the output at the given
+	// position, where the primary output is at position 0.  A nil limiter
+	// removes the cap.  Positions outside the outputs are ignored.
+	//
+	// Writes wait for every limited output in turn, so a limited secondary
+	// slows down the whole writer.  Wrap the secondary in an AsyncWriteCloser
+	// and limit that instead to keep the primary at full speed.
+	Limit(index int, limiter *Limiter) SplitWriteCloser
+
+	// Context sets the context used to cancel waits on limiters.  A cancelled
+	// wait fails the write to that output with the context's error.  Defaults
+	// to context.Background().
+	Context(context.Context) SplitWriteCloser
+
+	// Progress sets a function to be called with a progress report at most
+	// once per interval while writing, and once more when it is closed or has
+	// written the size given to ExpectSize.  Bytes are counted as accepted by
+	// the primary output.
+	Progress(interval time.Duration, fn ProgressFunc) SplitWriteCloser
+
+	// ExpectSize sets the total number of bytes expected to be written, used
+	// to estimate the time remaining in progress reports.  StreamSize can be
+	// used to find the size of the writer's source.
+	ExpectSize(int64) SplitWriteCloser
+
+	// Digest adds hashes that are fed every byte accepted by the primary
+	// output, so the digests of the stream can be read from them once writing
+	// is done.  Digest should be called before the first write.
+	Digest(...hash.Hash) SplitWriteCloser
+
+	// Verify enables verification of the outputs on Close.  Before the outputs
+	// are closed, each output that can be read back, such as an *os.File
+	// opened for reading and writing, is re-read and its checksum, computed
+	// with a hash from the given function, is compared with that of the
+	// stream.  Outputs that cannot be read back, including files opened
+	// write-only, are not verified.  A nil function disables verification.
+	// Verify should be called before the first write.
+	//
+	// Failures are returned from Close as StreamError values with the op
+	// OpVerify, wrapping ErrChecksumMismatch, ErrSizeMismatch or the error
+	// that stopped the output being read.  Failures of secondary outputs are
+	// dropped if IgnoreErrors is set.
+	Verify(newHash func() hash.Hash) SplitWriteCloser
+
+	// Transform wraps the secondary output at the given position, where the
+	// first secondary is at position 1, in a chain of transformers.  Data
+	// passes through the transformers in the order they are given before
+	// reaching the output.  Other outputs, including the primary, still
+	// receive the raw stream.  Calls with position 0, the primary, or a
+	// position past the last secondary are ignored.
+	//
+	// On Close, the transformers are closed in order so each writes out its
+	// remaining data before the next is closed, and then the output is closed.
+	// Calling Transform again for the same output places the new chain in
+	// front of the existing one.  Transform should be called before the first
+	// write.
+	Transform(index int, chain ...Transformer) SplitWriteCloser
 }
 
 // NewSplitWriteCloser constructs a new SplitWriteCloser instance with the given
 // primary and secondary writers.
+//
+// Errors returned from the outputs are wrapped in a *StreamError identifying
+// the failing output.
 func NewSplitWriteCloser(
 	raw io.WriteCloser,
 	addtl ...io.WriteCloser,
@@ -21,50 +116,229 @@ func NewSplitWriteCloser(
 	return &splitWriteCloser{
 		primary:   raw,
 		secondary: addtl,
+		expected:  -1,
 	}
 }
 
 type splitWriteCloser struct {
 	primary    io.WriteCloser
 	secondary  []io.WriteCloser
+	names      []string
 	ignoreErrs bool
+	closed     bool
+
+	// scratch backs single byte writes to outputs that are not io.ByteWriters.
+	scratch [1]byte
+
+	// meter records writes to the outputs, nil unless instrumented.
+	meter *meter
+
+	limits []*Limiter
+	ctx    context.Context
+
+	progress *progress
+	expected int64
+
+	checks *checksums
 }
 
 func (s *splitWriteCloser) Write(p []byte) (n int, err error) {
-	if n, err = s.primary.Write(p); err != nil {
-		return
+	if s.closed {
+		return 0, os.ErrClosed
 	}
 
-	for _, w := range s.secondary {
-		if _, err := w.Write(p); err != nil && !s.ignoreErrs {
-			return n, err
-		}
-	}
+	n, err = internalWrite(s, p)
+	s.checks.add(p[:n])
 
-	return n, nil
+	return
 }
 
-func (s *splitWriteCloser) Close() (err error) {
-	var errs []error
+// Close closes every output, primary first, after verifying them if Verify was
+// set.  Later calls return nil, and writes after Close return os.ErrClosed.
+func (s *splitWriteCloser) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+	s.progress.finish(0)
+
+	errs := NewMultiErrorBuilder()
+
+	for i := 0; i < s.outputCount(); i++ {
+		if e := s.checks.verify(s.output(i)); e != nil && (i == 0 || !s.ignoreErrs) {
+			errs.Add(s.outputError(OpVerify, i, e))
+		}
+	}
 
 	if e := s.primary.Close(); e != nil {
-		errs = append(errs, e)
+		errs.Add(s.outputError(OpClose, 0, e))
 	}
 
-	for _, w := range s.secondary {
+	for i, w := range s.secondary {
 		if e := w.Close(); e != nil && !s.ignoreErrs {
-			errs = append(errs, e)
+			errs.Add(s.outputError(OpClose, i+1, e))
 		}
 	}
 
-	if len(errs) > 0 {
-		err = NewMultiError(errs)
+	return errs.Build()
+}
+
+// WriteString writes the given string to every output.
+//
+// Outputs that implement io.StringWriter are given the string directly, all
+// other outputs share a single byte slice copy of it.
+func (s *splitWriteCloser) WriteString(str string) (int, error) {
+	if s.closed {
+		return 0, os.ErrClosed
 	}
 
-	return
+	n, err := internalWriteString(s, str)
+
+	if n > len(str) {
+		n = len(str)
+	}
+
+	s.checks.addString(str[:n])
+
+	return n, err
+}
+
+// WriteByte writes the given byte to every output.
+//
+// Outputs that implement io.ByteWriter are given the byte directly, all other
+// outputs are given a one byte slice that is reused between calls.
+func (s *splitWriteCloser) WriteByte(c byte) error {
+	if s.closed {
+		return os.ErrClosed
+	}
+
+	err := internalWriteByte(s, c, s.scratch[:])
+
+	if primaryWrote(err) {
+		s.scratch[0] = c
+		s.checks.add(s.scratch[:1])
+	}
+
+	return err
 }
 
 func (s *splitWriteCloser) IgnoreErrors(b bool) SplitWriteCloser {
 	s.ignoreErrs = b
 	return s
 }
+
+func (s *splitWriteCloser) Names(names ...string) SplitWriteCloser {
+	s.names = names
+	return s
+}
+
+func (s *splitWriteCloser) outputError(op string, index int, err error) error {
+	return newStreamError(op, outputRole(index), index, s.names, err)
+}
+
+func (s *splitWriteCloser) Flush() error {
+	return internalFlush(s)
+}
+
+func (s *splitWriteCloser) Sync() error {
+	return internalSync(s)
+}
+
+func (s *splitWriteCloser) outputCount() int {
+	return len(s.secondary) + 1
+}
+
+func (s *splitWriteCloser) output(index int) io.Writer {
+	if index == 0 {
+		return s.primary
+	}
+
+	return s.secondary[index-1]
+}
+
+func (s *splitWriteCloser) Instrument(obs ...Observer) SplitWriteCloser {
+	s.meter = newMeter(s.outputCount(), outputRole, &s.names, obs)
+	return s
+}
+
+func (s *splitWriteCloser) Metrics() Metrics {
+	return s.meter.snapshot()
+}
+
+func (s *splitWriteCloser) outputMeter() *meter {
+	return s.meter
+}
+
+func (s *splitWriteCloser) Limit(index int, limiter *Limiter) SplitWriteCloser {
+	s.limits = setLimiter(s.limits, s.outputCount(), index, limiter)
+	return s
+}
+
+func (s *splitWriteCloser) Context(ctx context.Context) SplitWriteCloser {
+	s.ctx = ctx
+	return s
+}
+
+func (s *splitWriteCloser) Progress(interval time.Duration, fn ProgressFunc) SplitWriteCloser {
+	s.progress = newProgress(interval, fn, s.expected, 0)
+	return s
+}
+
+func (s *splitWriteCloser) ExpectSize(n int64) SplitWriteCloser {
+	s.expected = n
+
+	if s.progress != nil {
+		s.progress.total = n
+	}
+
+	return s
+}
+
+func (s *splitWriteCloser) Transform(index int, chain ...Transformer) SplitWriteCloser {
+	if index > 0 && index <= len(s.secondary) {
+		s.secondary[index-1] = newTransformChain(s.secondary[index-1], chain)
+	}
+
+	return s
+}
+
+func (s *splitWriteCloser) Digest(hashes ...hash.Hash) SplitWriteCloser {
+	if s.checks == nil {
+		s.checks = new(checksums)
+	}
+
+	s.checks.digests = append(s.checks.digests, hashes...)
+	return s
+}
+
+func (s *splitWriteCloser) Verify(newHash func() hash.Hash) SplitWriteCloser {
+	if s.checks == nil {
+		s.checks = new(checksums)
+	}
+
+	s.checks.newHash = newHash
+	s.checks.sum = nil
+
+	if newHash != nil {
+		s.checks.sum = newHash()
+	}
+
+	return s
+}
+
+func (s *splitWriteCloser) outputProgress() *progress {
+	return s.progress
+}
+
+func (s *splitWriteCloser) outputLimiter(index int) *Limiter {
+	return limiterAt(s.limits, index)
+}
+
+func (s *splitWriteCloser) waitContext() context.Context {
+	return orBackground(s.ctx)
+}
+
+func (s *splitWriteCloser) ignoresErrors() bool {
+	return s.ignoreErrs
+}
@@ -2,6 +2,8 @@ package spipe_test
 
 import (
 	"errors"
+	"io"
+	"os"
 	"strings"
 	"testing"
 
@@ -44,7 +46,12 @@ func TestSplitWriteCloser_Write(t *testing.T) {
 				test := spipe.NewSplitWriteCloser(a, b, c)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldResemble, b.WriteErrors[0])
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role:  spipe.RoleSecondary,
+					Index: 1,
+					Op:    spipe.OpWrite,
+					Err:   b.WriteErrors[0],
+				})
 				So(n, ShouldEqual, 5)
 				So(a.WrittenBytes, ShouldResemble, []byte("hello"))
 				So(b.WrittenBytes, ShouldResemble, []byte("hello"))
@@ -77,7 +84,11 @@ func TestSplitWriteCloser_Write(t *testing.T) {
 				test := spipe.NewSplitWriteCloser(a, b, c)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldResemble, a.WriteErrors[0])
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role: spipe.RolePrimary,
+					Op:   spipe.OpWrite,
+					Err:  a.WriteErrors[0],
+				})
 				So(n, ShouldEqual, 5)
 				So(a.WrittenBytes, ShouldResemble, []byte("hello"))
 				So(b.WrittenBytes, ShouldBeEmpty)
@@ -92,13 +103,41 @@ func TestSplitWriteCloser_Write(t *testing.T) {
 				test := spipe.NewSplitWriteCloser(a, b, c)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldResemble, a.WriteErrors[0])
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role: spipe.RolePrimary,
+					Op:   spipe.OpWrite,
+					Err:  a.WriteErrors[0],
+				})
 				So(n, ShouldEqual, 5)
 				So(a.WrittenBytes, ShouldResemble, []byte("hello"))
 				So(b.WrittenBytes, ShouldBeEmpty)
 				So(c.WrittenBytes, ShouldBeEmpty)
 			})
 		})
+
+		Convey("short secondary", func() {
+			a := new(WriteCloser)
+			b := &WriteCloser{WriteCounts: []int{3}}
+
+			test := spipe.NewSplitWriteCloser(a, b)
+			n, err := test.Write([]byte("hello"))
+
+			So(err, ShouldResemble, &spipe.StreamError{
+				Role:  spipe.RoleSecondary,
+				Index: 1,
+				Op:    spipe.OpWrite,
+				Err:   io.ErrShortWrite,
+			})
+			So(n, ShouldEqual, 5)
+
+			Convey("with ignore", func() {
+				b := &WriteCloser{WriteCounts: []int{3}}
+
+				n, err := spipe.NewSplitWriteCloser(new(WriteCloser), b).IgnoreErrors(true).Write([]byte("hello"))
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 5)
+			})
+		})
 	})
 }
 
@@ -116,6 +155,12 @@ func TestSplitWriteCloser_Close(t *testing.T) {
 
 			So(err, ShouldBeNil)
 			So(val, ShouldEqual, 3)
+
+			So(test.Close(), ShouldBeNil)
+			So(val, ShouldEqual, 3)
+
+			_, err = test.Write([]byte("late"))
+			So(err, ShouldEqual, os.ErrClosed)
 		})
 
 		Convey("errors", func() {
@@ -128,7 +173,9 @@ func TestSplitWriteCloser_Close(t *testing.T) {
 
 			So(ok, ShouldBeTrue)
 			So(err, ShouldNotBeNil)
-			So(err.Error(), ShouldEqual, "hi\nhi\nhi")
+			So(err.Error(), ShouldEqual, "Close primary 0: hi\n"+
+				"Close secondary 1: hi\n"+
+				"Close secondary 2: hi")
 		})
 	})
 }
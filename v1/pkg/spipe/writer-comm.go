@@ -0,0 +1,245 @@
+package spipe
+
+import (
+	"context"
+	"io"
+)
+
+type writer interface {
+	// outputCount returns the number of outputs, primary included.
+	outputCount() int
+
+	// output returns the output at the given position, where the primary
+	// output is at position 0.
+	output(index int) io.Writer
+
+	// ignoresErrors returns whether errors from secondary outputs are ignored.
+	ignoresErrors() bool
+
+	// outputError wraps the given error in a StreamError attributed to the
+	// output at the given position.
+	outputError(op string, index int, err error) error
+
+	// outputMeter returns the meter recording calls to the outputs, or nil if
+	// the writer is not instrumented.
+	outputMeter() *meter
+
+	// outputLimiter returns the limiter for the output at the given position, or
+	// nil if the output is not rate limited.
+	outputLimiter(index int) *Limiter
+
+	// waitContext returns the context used to cancel waits on limiters.
+	waitContext() context.Context
+
+	// outputProgress returns the progress tracker for the writer, or nil if
+	// progress is not being reported.
+	outputProgress() *progress
+}
+
+type errFlusher interface {
+	Flush() error
+}
+
+// plainFlusher matches outputs such as http.Flusher whose Flush method does not
+// return an error.
+type plainFlusher interface {
+	Flush()
+}
+
+type syncer interface {
+	Sync() error
+}
+
+// internalFlush calls Flush on every output that supports it.
+func internalFlush(w writer) error {
+	return eachOutput(w, OpFlush, flushWriter)
+}
+
+// flushWriter calls Flush on the given writer if it supports it.
+func flushWriter(w io.Writer) error {
+	switch f := w.(type) {
+	case errFlusher:
+		return f.Flush()
+	case plainFlusher:
+		f.Flush()
+	}
+
+	return nil
+}
+
+// internalSync calls Sync on every output that supports it.
+func internalSync(w writer) error {
+	return eachOutput(w, OpSync, func(out io.Writer) error {
+		if s, ok := out.(syncer); ok {
+			return s.Sync()
+		}
+
+		return nil
+	})
+}
+
+// eachOutput calls the given function for every output, primary first,
+// collecting the returned errors into a MultiError.
+//
+// Errors from secondary outputs are dropped if the writer ignores errors.
+func eachOutput(w writer, op string, fn func(io.Writer) error) error {
+	errs := NewMultiErrorBuilder()
+
+	for i := 0; i < w.outputCount(); i++ {
+		if e := fn(w.output(i)); e != nil && (i == 0 || !w.ignoresErrors()) {
+			errs.Add(w.outputError(op, i, e))
+		}
+	}
+
+	return errs.Build()
+}
+
+// waitOutput blocks until the limiter of the output at the given position, if
+// any, allows n bytes through.
+func waitOutput(w writer, index, n int) error {
+	if l := w.outputLimiter(index); l != nil {
+		return l.WaitN(w.waitContext(), n)
+	}
+
+	return nil
+}
+
+// writeOutput writes p to the output at the given position once its limiter
+// allows it, recording the call if the writer is instrumented.
+func writeOutput(w writer, index int, p []byte) (int, error) {
+	if err := waitOutput(w, index, len(p)); err != nil {
+		return 0, err
+	}
+
+	return w.outputMeter().observe(index, OpWrite, func() (int, error) {
+		return w.output(index).Write(p)
+	})
+}
+
+// internalWrite writes p to every output, primary first.
+//
+// A write that is short is reported as io.ErrShortWrite.  Errors from
+// secondary outputs, short writes included, are dropped if the writer ignores
+// errors.  The returned count is always the number of bytes the primary
+// output accepted.
+func internalWrite(w writer, p []byte) (n int, err error) {
+	if n, err = writeOutput(w, 0, p); err != nil {
+		return n, w.outputError(OpWrite, 0, err)
+	}
+
+	if n < len(p) {
+		return n, w.outputError(OpWrite, 0, io.ErrShortWrite)
+	}
+
+	for i := 1; i < w.outputCount(); i++ {
+		m, e := writeOutput(w, i, p)
+
+		if w.ignoresErrors() {
+			continue
+		}
+
+		if e != nil {
+			return n, w.outputError(OpWrite, i, e)
+		}
+
+		if m < len(p) {
+			return n, w.outputError(OpWrite, i, io.ErrShortWrite)
+		}
+	}
+
+	w.outputProgress().add(n, 0)
+
+	return n, nil
+}
+
+// internalWriteString writes the given string to every output, using the
+// output's WriteString method where available so the string does not need to be
+// converted to a byte slice.
+//
+// Outputs without a WriteString method share a single converted copy of the
+// string.
+func internalWriteString(w writer, s string) (n int, err error) {
+	var p []byte
+
+	for i := 0; i < w.outputCount(); i++ {
+		if e := waitOutput(w, i, len(s)); e != nil {
+			if i == 0 || !w.ignoresErrors() {
+				return n, w.outputError(OpWrite, i, e)
+			}
+
+			continue
+		}
+
+		m, e := w.outputMeter().observe(i, OpWrite, func() (int, error) {
+			if sw, ok := w.output(i).(io.StringWriter); ok {
+				return sw.WriteString(s)
+			}
+
+			if p == nil {
+				p = []byte(s)
+			}
+
+			return w.output(i).Write(p)
+		})
+
+		if i == 0 {
+			n = m
+		} else if w.ignoresErrors() {
+			continue
+		}
+
+		if e != nil {
+			return n, w.outputError(OpWrite, i, e)
+		}
+
+		if m < len(s) {
+			return n, w.outputError(OpWrite, i, io.ErrShortWrite)
+		}
+	}
+
+	w.outputProgress().add(n, 0)
+
+	return
+}
+
+// internalWriteByte writes the given byte to every output, using the output's
+// WriteByte method where available.
+//
+// Outputs without a WriteByte method are given the byte through the given one
+// byte scratch buffer, which avoids allocating a new slice on every call.
+func internalWriteByte(w writer, c byte, scratch []byte) error {
+	for i := 0; i < w.outputCount(); i++ {
+		if e := waitOutput(w, i, 1); e != nil {
+			if i == 0 || !w.ignoresErrors() {
+				return w.outputError(OpWrite, i, e)
+			}
+
+			continue
+		}
+
+		_, e := w.outputMeter().observe(i, OpWrite, func() (int, error) {
+			var e error
+
+			if bw, ok := w.output(i).(io.ByteWriter); ok {
+				e = bw.WriteByte(c)
+			} else {
+				scratch[0] = c
+				e = writeRecordTo(w.output(i), scratch[:1])
+			}
+
+			if e != nil {
+				return 0, e
+			}
+
+			return 1, nil
+		})
+
+		if e != nil && (i == 0 || !w.ignoresErrors()) {
+			return w.outputError(OpWrite, i, e)
+		}
+	}
+
+	w.outputProgress().add(1, 0)
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package spipe_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// discardString is an io.Writer, io.StringWriter and io.ByteWriter that throws
+// away everything written to it.
+type discardString struct{}
+
+func (discardString) Write(p []byte) (int, error)       { return len(p), nil }
+func (discardString) WriteString(s string) (int, error) { return len(s), nil }
+func (discardString) WriteByte(byte) error              { return nil }
+
+// discardBytes is an io.Writer that throws away everything written to it and
+// implements no other write methods.
+type discardBytes struct{}
+
+func (discardBytes) Write(p []byte) (int, error) { return len(p), nil }
+
+var benchString = strings.Repeat("benchmark ", 10)
+
+func BenchmarkSplitWriter_Write_convertedString(b *testing.B) {
+	test := spipe.NewSplitWriter(discardString{}, discardString{}, discardString{})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		test.Write([]byte(benchString))
+	}
+}
+
+func BenchmarkSplitWriter_WriteString_native(b *testing.B) {
+	test := spipe.NewSplitWriter(discardString{}, discardString{}, discardString{})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		io.WriteString(test, benchString)
+	}
+}
+
+func BenchmarkSplitWriter_WriteString_fallback(b *testing.B) {
+	test := spipe.NewSplitWriter(discardBytes{}, discardBytes{}, discardBytes{})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		io.WriteString(test, benchString)
+	}
+}
+
+func BenchmarkSplitWriter_Write_singleByte(b *testing.B) {
+	test := spipe.NewSplitWriter(discardBytes{}, discardBytes{}, discardBytes{})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		test.Write([]byte{'x'})
+	}
+}
+
+func BenchmarkSplitWriter_WriteByte_native(b *testing.B) {
+	test := spipe.NewSplitWriter(discardString{}, discardString{}, discardString{})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		test.WriteByte('x')
+	}
+}
+
+func BenchmarkSplitWriter_WriteByte_fallback(b *testing.B) {
+	test := spipe.NewSplitWriter(discardBytes{}, discardBytes{}, discardBytes{})
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		test.WriteByte('x')
+	}
+}
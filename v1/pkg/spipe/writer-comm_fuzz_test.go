@@ -0,0 +1,243 @@
+//go:build go1.18
+// +build go1.18
+
+package spipe_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// fuzzWriter accepts writes until failAt bytes have been written, if failAt is
+// not negative, after which it fails with errFuzz or, if short is set, returns
+// short counts with no error.
+//
+// It implements no write methods other than Write so every write made through
+// a split writer reaches it.
+type fuzzWriter struct {
+	buf     bytes.Buffer
+	failAt  int
+	short   bool
+	faulted bool
+}
+
+func (w *fuzzWriter) Write(p []byte) (int, error) {
+	if w.failAt < 0 || w.buf.Len()+len(p) <= w.failAt {
+		return w.buf.Write(p)
+	}
+
+	n := w.failAt - w.buf.Len()
+	w.buf.Write(p[:n])
+	w.faulted = true
+
+	if w.short {
+		return n, nil
+	}
+
+	return n, errFuzz
+}
+
+func (w *fuzzWriter) Close() error {
+	return nil
+}
+
+// fuzzOutputs builds up to four outputs from plan, one byte per output.  The
+// low two bits of each byte pick a healthy, short writing or failing output and
+// the rest give the failure position.
+func fuzzOutputs(plan []byte) []*fuzzWriter {
+	if len(plan) > 4 {
+		plan = plan[:4]
+	}
+
+	outputs := []*fuzzWriter{{failAt: -1}}
+
+	if len(plan) > 0 {
+		outputs = outputs[:0]
+	}
+
+	for _, b := range plan {
+		out := &fuzzWriter{failAt: -1}
+
+		switch b & 3 {
+		case 1:
+			out.short = true
+			fallthrough
+		case 2:
+			out.failAt = int(b>>2) * 4
+		}
+
+		outputs = append(outputs, out)
+	}
+
+	return outputs
+}
+
+// splitUnderFuzz is the part of SplitWriter and SplitWriteCloser exercised by
+// the fuzz target.
+type splitUnderFuzz interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+}
+
+func FuzzSplitWriter_Write(f *testing.F) {
+	f.Add([]byte("hello world"), []byte{0, 0, 0}, []byte{4})
+	f.Add([]byte("hello world"), []byte{0, 0, 0x09}, []byte{3, 0x83, 0xc1})
+	f.Add([]byte("hello world"), []byte{1, 0x0a, 0, 0x05}, []byte{5, 0x82})
+	f.Add([]byte("abcdefghijklmnopqrstuvwxyz"), []byte{0, 0x11, 0x22}, []byte{0, 7, 0xc1, 0x8a})
+
+	f.Fuzz(func(t *testing.T, data, plan, sizes []byte) {
+		ignore := len(plan) > 0 && plan[0]&1 != 0
+		if len(plan) > 0 {
+			plan = plan[1:]
+		}
+
+		constructors := []struct {
+			name      string
+			construct func(outputs []*fuzzWriter) splitUnderFuzz
+		}{
+			{"SplitWriter", func(outputs []*fuzzWriter) splitUnderFuzz {
+				addtl := make([]io.Writer, len(outputs)-1)
+				for i, w := range outputs[1:] {
+					addtl[i] = w
+				}
+
+				return spipe.NewSplitWriter(outputs[0], addtl...).IgnoreErrors(ignore)
+			}},
+			{"SplitWriteCloser", func(outputs []*fuzzWriter) splitUnderFuzz {
+				addtl := make([]io.WriteCloser, len(outputs)-1)
+				for i, w := range outputs[1:] {
+					addtl[i] = w
+				}
+
+				return spipe.NewSplitWriteCloser(outputs[0], addtl...).IgnoreErrors(ignore)
+			}},
+		}
+
+		var results [][]string
+		var written [][]string
+
+		for _, c := range constructors {
+			outputs := fuzzOutputs(plan)
+			calls := fuzzWriteAll(t, c.name, c.construct(outputs), outputs, data, sizes, ignore)
+
+			contents := make([]string, len(outputs))
+			for i, w := range outputs {
+				contents[i] = w.buf.String()
+			}
+
+			results = append(results, calls)
+			written = append(written, contents)
+		}
+
+		if fmt.Sprint(results[0]) != fmt.Sprint(results[1]) {
+			t.Fatalf("SplitWriter returned %q, SplitWriteCloser returned %q", results[0], results[1])
+		}
+
+		if fmt.Sprint(written[0]) != fmt.Sprint(written[1]) {
+			t.Fatalf("SplitWriter wrote %q, SplitWriteCloser wrote %q", written[0], written[1])
+		}
+	})
+}
+
+// fuzzWriteAll writes data to w in chunks with the given sizes in turn,
+// checking each result and then the content of the outputs.  The results of
+// the calls are returned.
+func fuzzWriteAll(
+	t *testing.T,
+	name string,
+	w splitUnderFuzz,
+	outputs []*fuzzWriter,
+	data, sizes []byte,
+	ignore bool,
+) (calls []string) {
+	accepted, last := 0, 0
+	failed := false
+
+	for i := 0; accepted < len(data) && i < 10000; i++ {
+		size, mode := 16, byte(0)
+		if len(sizes) > 0 {
+			size, mode = int(sizes[i%len(sizes)]&0x3f), sizes[i%len(sizes)]>>6
+		}
+
+		if size > len(data)-accepted {
+			size = len(data) - accepted
+		}
+
+		chunk := data[accepted : accepted+size]
+
+		var n int
+		var err error
+
+		switch mode {
+		case 2:
+			n, err = w.WriteString(string(chunk))
+		case 3:
+			chunk = chunk[:0]
+			if size > 0 {
+				chunk = data[accepted : accepted+1]
+
+				if err = w.WriteByte(chunk[0]); err == nil {
+					n = 1
+				}
+			}
+		default:
+			n, err = w.Write(chunk)
+		}
+
+		calls = append(calls, fmt.Sprintf("%d %v", n, err))
+		last = len(chunk)
+
+		if n < 0 || n > len(chunk) {
+			t.Fatalf("%s: write returned n = %d for %d bytes", name, n, len(chunk))
+		}
+
+		if err == nil {
+			if n != len(chunk) {
+				t.Fatalf("%s: write returned n = %d for %d bytes without an error", name, n, len(chunk))
+			}
+
+			accepted += n
+			continue
+		}
+
+		var streamErr *spipe.StreamError
+		if !errors.As(err, &streamErr) || !(errors.Is(err, errFuzz) || errors.Is(err, io.ErrShortWrite)) {
+			t.Fatalf("%s: write returned %v, want a StreamError for a failed or short write", name, err)
+		}
+
+		if !outputs[streamErr.Index].faulted || (ignore && streamErr.Index != 0) {
+			t.Fatalf("%s: write returned %v, blaming a healthy or ignored output", name, err)
+		}
+
+		failed = true
+		break
+	}
+
+	if !failed {
+		last = 0
+	}
+
+	for i, out := range outputs {
+		got := out.buf.Bytes()
+
+		if len(got) > accepted+last || !bytes.Equal(got, data[:len(got)]) {
+			t.Fatalf("%s: output %d holds %q, want a prefix of %q", name, i, got, data[:accepted+last])
+		}
+
+		if !out.faulted && len(got) < accepted {
+			t.Fatalf("%s: output %d holds %q, want at least %q", name, i, got, data[:accepted])
+		}
+
+		if !out.faulted && !failed && len(got) != accepted {
+			t.Fatalf("%s: output %d holds %q, want %q", name, i, got, data[:accepted])
+		}
+	}
+
+	return calls
+}
@@ -0,0 +1,277 @@
+package spipe_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/vulpine-io/io-test/v1/pkg/iotest"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+type flushSyncer struct {
+	strings.Builder
+	flushErr error
+	syncErr  error
+	flushes  int
+	syncs    int
+}
+
+func (f *flushSyncer) Flush() error { f.flushes++; return f.flushErr }
+func (f *flushSyncer) Sync() error  { f.syncs++; return f.syncErr }
+func (f *flushSyncer) Close() error { return nil }
+
+type commSplitter interface {
+	io.Writer
+	io.StringWriter
+	io.ByteWriter
+	Flush() error
+	Sync() error
+}
+
+// stringByteWC is an output implementing io.StringWriter and io.ByteWriter
+// that records which write methods were used.
+type stringByteWC struct {
+	bytes.Buffer
+	writes, stringWrites, byteWrites int
+}
+
+func (s *stringByteWC) Write(p []byte) (int, error) {
+	s.writes++
+	return s.Buffer.Write(p)
+}
+
+func (s *stringByteWC) WriteString(str string) (int, error) {
+	s.stringWrites++
+	return s.Buffer.WriteString(str)
+}
+
+func (s *stringByteWC) WriteByte(c byte) error {
+	s.byteWrites++
+	return s.Buffer.WriteByte(c)
+}
+
+func (s *stringByteWC) Close() error { return nil }
+
+func tWriterComm(construct func(ignore bool, outputs ...io.WriteCloser) commSplitter) {
+	Convey("WriteString", func() {
+		Convey("native and fallback outputs", func() {
+			a := new(stringByteWC)
+			b := new(WriteCloser)
+			c := new(stringByteWC)
+
+			n, err := construct(false, a, b, c).WriteString("hello")
+
+			So(err, ShouldBeNil)
+			So(n, ShouldEqual, 5)
+			So(a.String(), ShouldEqual, "hello")
+			So(string(b.WrittenBytes), ShouldEqual, "hello")
+			So(c.String(), ShouldEqual, "hello")
+			So(a.stringWrites, ShouldEqual, 1)
+			So(a.writes, ShouldEqual, 0)
+			So(b.WriteCalls, ShouldEqual, 1)
+		})
+
+		Convey("failing secondary", func() {
+			Convey("without ignore", func() {
+				a := new(stringByteWC)
+				b := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+				c := new(stringByteWC)
+
+				_, err := construct(false, a, b, c).WriteString("hello")
+
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role:  spipe.RoleSecondary,
+					Index: 1,
+					Op:    spipe.OpWrite,
+					Err:   errors.New("hiya!"),
+				})
+				So(c.Len(), ShouldEqual, 0)
+			})
+
+			Convey("with ignore", func() {
+				a := new(stringByteWC)
+				b := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+				c := new(stringByteWC)
+
+				n, err := construct(true, a, b, c).WriteString("hello")
+
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, 5)
+				So(c.String(), ShouldEqual, "hello")
+			})
+		})
+
+		Convey("short write on primary", func() {
+			a := &WriteCloser{WriteCounts: []int{2}}
+			b := new(stringByteWC)
+
+			n, err := construct(false, a, b).WriteString("hello")
+
+			So(n, ShouldEqual, 2)
+			So(errors.Is(err, io.ErrShortWrite), ShouldBeTrue)
+			So(b.Len(), ShouldEqual, 0)
+		})
+	})
+
+	Convey("WriteByte", func() {
+		Convey("native and fallback outputs", func() {
+			a := new(stringByteWC)
+			b := new(WriteCloser)
+
+			test := construct(false, a, b)
+
+			So(test.WriteByte('h'), ShouldBeNil)
+			So(test.WriteByte('i'), ShouldBeNil)
+			So(a.String(), ShouldEqual, "hi")
+			So(a.byteWrites, ShouldEqual, 2)
+			So(string(b.WrittenBytes), ShouldEqual, "hi")
+		})
+
+		Convey("failing primary", func() {
+			a := &WriteCloser{WriteErrors: []error{errors.New("hiya!")}}
+			b := new(stringByteWC)
+
+			err := construct(true, a, b).WriteByte('h')
+
+			So(errors.Is(err, a.WriteErrors[0]), ShouldBeTrue)
+			So(b.Len(), ShouldEqual, 0)
+		})
+
+		Convey("short write on secondary", func() {
+			Convey("without ignore", func() {
+				a := new(stringByteWC)
+				b := &WriteCloser{WriteCounts: []int{0}}
+
+				err := construct(false, a, b).WriteByte('h')
+
+				So(errors.Is(err, io.ErrShortWrite), ShouldBeTrue)
+			})
+
+			Convey("with ignore", func() {
+				a := new(stringByteWC)
+				b := &WriteCloser{WriteCounts: []int{0}}
+				c := new(stringByteWC)
+
+				So(construct(true, a, b, c).WriteByte('h'), ShouldBeNil)
+				So(c.String(), ShouldEqual, "h")
+			})
+		})
+	})
+
+	Convey("Flush", func() {
+		Convey("bufio and gzip outputs", func() {
+			raw := new(bytes.Buffer)
+			buf := bufio.NewWriter(raw)
+			zipped := new(bytes.Buffer)
+			gz := gzip.NewWriter(zipped)
+
+			test := construct(false, nopWC{buf}, nopWC{gz})
+
+			_, err := test.Write([]byte("hello"))
+			So(err, ShouldBeNil)
+			So(raw.Len(), ShouldEqual, 0)
+
+			So(test.Flush(), ShouldBeNil)
+			So(raw.String(), ShouldEqual, "hello")
+
+			So(gz.Close(), ShouldBeNil)
+			rd, err := gzip.NewReader(zipped)
+			So(err, ShouldBeNil)
+			out, _ := ioutil.ReadAll(rd)
+			So(string(out), ShouldEqual, "hello")
+		})
+
+		Convey("errors", func() {
+			a := &flushSyncer{flushErr: errors.New("a")}
+			b := &flushSyncer{flushErr: errors.New("b")}
+
+			err := construct(false, a, b).Flush()
+
+			So(err.(spipe.MultiError).Errors(), ShouldResemble, []error{
+				&spipe.StreamError{Role: spipe.RolePrimary, Op: spipe.OpFlush, Err: errors.New("a")},
+				&spipe.StreamError{Role: spipe.RoleSecondary, Index: 1, Op: spipe.OpFlush, Err: errors.New("b")},
+			})
+		})
+
+		Convey("ignored secondary errors", func() {
+			a := &flushSyncer{}
+			b := &flushSyncer{flushErr: errors.New("b")}
+
+			So(construct(true, a, b).Flush(), ShouldBeNil)
+			So(a.flushes, ShouldEqual, 1)
+			So(b.flushes, ShouldEqual, 1)
+		})
+	})
+
+	Convey("Sync", func() {
+		Convey("file output", func() {
+			f, err := ioutil.TempFile("", "spipe")
+			So(err, ShouldBeNil)
+			defer os.Remove(f.Name())
+			defer f.Close()
+
+			a := new(flushSyncer)
+			test := construct(false, a, f)
+
+			_, err = test.Write([]byte("hello"))
+			So(err, ShouldBeNil)
+			So(test.Sync(), ShouldBeNil)
+			So(a.syncs, ShouldEqual, 1)
+		})
+
+		Convey("errors", func() {
+			a := &flushSyncer{}
+			b := &flushSyncer{syncErr: errors.New("b")}
+
+			err := construct(false, a, b).Sync()
+
+			So(err.Error(), ShouldEqual, "Sync secondary 1: b")
+			So(construct(true, a, b).Sync(), ShouldBeNil)
+		})
+	})
+}
+
+type nopWC struct {
+	io.Writer
+}
+
+func (n nopWC) Close() error { return nil }
+
+func (n nopWC) Flush() error {
+	if f, ok := n.Writer.(interface{ Flush() error }); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+func TestSplitWriter_FlushSync(t *testing.T) {
+	Convey("SplitWriter", t, func() {
+		tWriterComm(func(ignore bool, outputs ...io.WriteCloser) commSplitter {
+			addtl := make([]io.Writer, len(outputs)-1)
+			for i := range addtl {
+				addtl[i] = outputs[i+1]
+			}
+
+			return spipe.NewSplitWriter(outputs[0], addtl...).IgnoreErrors(ignore)
+		})
+	})
+}
+
+func TestSplitWriteCloser_FlushSync(t *testing.T) {
+	Convey("SplitWriteCloser", t, func() {
+		tWriterComm(func(ignore bool, outputs ...io.WriteCloser) commSplitter {
+			return spipe.NewSplitWriteCloser(outputs[0], outputs[1:]...).
+				IgnoreErrors(ignore)
+		})
+	})
+}
@@ -1,6 +1,10 @@
 package spipe
 
-import "io"
+import (
+	"context"
+	"io"
+	"time"
+)
 
 // SplitWriter defines an io.Writer implementation that writes to multiple
 // outputs.
@@ -12,50 +16,197 @@ import "io"
 // as `io.MultiWriter`.
 type SplitWriter interface {
 	io.Writer
+	io.StringWriter
+	io.ByteWriter
 
 	// IgnoreErrors sets whether or not the split writer should ignore errors
 	// returned from secondary writers.
 	IgnoreErrors(bool) SplitWriter
+
+	// Names sets the names used to identify the outputs in returned
+	// StreamError values.  Names are given in the same order as the writers
+	// were given to the constructor, primary first.
+	Names(...string) SplitWriter
+
+	// Flush calls Flush on every output that implements it, such as
+	// *bufio.Writer or *gzip.Writer.
+	//
+	// Errors are collected into a MultiError.  Errors from secondary writers
+	// are dropped if IgnoreErrors is set.
+	Flush() error
+
+	// Sync calls Sync on every output that implements it, such as *os.File.
+	//
+	// Errors are collected into a MultiError.  Errors from secondary writers
+	// are dropped if IgnoreErrors is set.
+	Sync() error
+
+	// Instrument enables the collection of Metrics for the outputs, passing an
+	// Observation for every write to each of the given observers.
+	Instrument(...Observer) SplitWriter
+
+	// Metrics returns a snapshot of the counters collected for the outputs,
+	// primary first.  The snapshot is empty if Instrument has not been called.
+	Metrics() Metrics
+
+	// Limit caps the rate at which bytes are written to the output at the given
+	// position, where the primary output is at position 0.  A nil limiter
+	// removes the cap.  Positions outside the outputs are ignored.
+	//
+	// Writes wait for every limited output in turn, so a limited secondary
+	// slows down the whole writer.  Wrap the secondary in an AsyncWriteCloser
+	// and limit that instead to keep the primary at full speed.
+	Limit(index int, limiter *Limiter) SplitWriter
+
+	// Context sets the context used to cancel waits on limiters.  A cancelled
+	// wait fails the write to that output with the context's error.  Defaults
+	// to context.Background().
+	Context(context.Context) SplitWriter
+
+	// Progress sets a function to be called with a progress report at most
+	// once per interval while writing, and once more when it has written the
+	// size given to ExpectSize.  Bytes are counted as accepted by the primary
+	// output.
+	Progress(interval time.Duration, fn ProgressFunc) SplitWriter
+
+	// ExpectSize sets the total number of bytes expected to be written, used
+	// to estimate the time remaining in progress reports.  StreamSize can be
+	// used to find the size of the writer's source.
+	ExpectSize(int64) SplitWriter
 }
 
 // NewSplitWriter constructs a new SplitWriter instance with the given primary
 // and secondary writers.
+//
+// Errors returned from the outputs are wrapped in a *StreamError identifying
+// the failing output.
 func NewSplitWriter(raw io.Writer, addtl ...io.Writer) SplitWriter {
-	return &splitWriter{primary: raw, secondary: addtl}
+	return &splitWriter{primary: raw, secondary: addtl, expected: -1}
 }
 
 type splitWriter struct {
 	primary    io.Writer
 	secondary  []io.Writer
+	names      []string
 	ignoreErrs bool
-}
 
-func (s *splitWriter) Write(p []byte) (n int, err error) {
-	if n, err = s.primary.Write(p); err != nil {
-		return
-	}
+	// scratch backs single byte writes to outputs that are not io.ByteWriters.
+	scratch [1]byte
 
-	if n < len(p) {
-		err = io.ErrShortWrite
-		return
-	}
+	// meter records writes to the outputs, nil unless instrumented.
+	meter *meter
 
-	for _, w := range s.secondary {
-		m, err := w.Write(p)
+	limits []*Limiter
+	ctx    context.Context
 
-		if err != nil && !s.ignoreErrs {
-			return n, err
-		}
+	progress *progress
+	expected int64
+}
 
-		if m < len(p) && !s.ignoreErrs {
-			return m, io.ErrShortWrite
-		}
-	}
+func (s *splitWriter) Write(p []byte) (int, error) {
+	return internalWrite(s, p)
+}
 
-	return n, nil
+// WriteString writes the given string to every output.
+//
+// Outputs that implement io.StringWriter are given the string directly, all
+// other outputs share a single byte slice copy of it.
+func (s *splitWriter) WriteString(str string) (int, error) {
+	return internalWriteString(s, str)
+}
+
+// WriteByte writes the given byte to every output.
+//
+// Outputs that implement io.ByteWriter are given the byte directly, all other
+// outputs are given a one byte slice that is reused between calls.
+func (s *splitWriter) WriteByte(c byte) error {
+	return internalWriteByte(s, c, s.scratch[:])
 }
 
 func (s *splitWriter) IgnoreErrors(b bool) SplitWriter {
 	s.ignoreErrs = b
 	return s
 }
+
+func (s *splitWriter) Names(names ...string) SplitWriter {
+	s.names = names
+	return s
+}
+
+func (s *splitWriter) outputError(op string, index int, err error) error {
+	return newStreamError(op, outputRole(index), index, s.names, err)
+}
+
+func (s *splitWriter) Flush() error {
+	return internalFlush(s)
+}
+
+func (s *splitWriter) Sync() error {
+	return internalSync(s)
+}
+
+func (s *splitWriter) outputCount() int {
+	return len(s.secondary) + 1
+}
+
+func (s *splitWriter) output(index int) io.Writer {
+	if index == 0 {
+		return s.primary
+	}
+
+	return s.secondary[index-1]
+}
+
+func (s *splitWriter) Instrument(obs ...Observer) SplitWriter {
+	s.meter = newMeter(s.outputCount(), outputRole, &s.names, obs)
+	return s
+}
+
+func (s *splitWriter) Metrics() Metrics {
+	return s.meter.snapshot()
+}
+
+func (s *splitWriter) outputMeter() *meter {
+	return s.meter
+}
+
+func (s *splitWriter) Limit(index int, limiter *Limiter) SplitWriter {
+	s.limits = setLimiter(s.limits, s.outputCount(), index, limiter)
+	return s
+}
+
+func (s *splitWriter) Context(ctx context.Context) SplitWriter {
+	s.ctx = ctx
+	return s
+}
+
+func (s *splitWriter) Progress(interval time.Duration, fn ProgressFunc) SplitWriter {
+	s.progress = newProgress(interval, fn, s.expected, 0)
+	return s
+}
+
+func (s *splitWriter) ExpectSize(n int64) SplitWriter {
+	s.expected = n
+
+	if s.progress != nil {
+		s.progress.total = n
+	}
+
+	return s
+}
+
+func (s *splitWriter) outputProgress() *progress {
+	return s.progress
+}
+
+func (s *splitWriter) outputLimiter(index int) *Limiter {
+	return limiterAt(s.limits, index)
+}
+
+func (s *splitWriter) waitContext() context.Context {
+	return orBackground(s.ctx)
+}
+
+func (s *splitWriter) ignoresErrors() bool {
+	return s.ignoreErrs
+}
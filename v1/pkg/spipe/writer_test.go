@@ -39,7 +39,12 @@ func TestSplitWriter_Write(t *testing.T) {
 				test := spipe.NewSplitWriter(a, b, c)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldResemble, b.WriteErrors[0])
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role:  spipe.RoleSecondary,
+					Index: 1,
+					Op:    spipe.OpWrite,
+					Err:   b.WriteErrors[0],
+				})
 				So(n, ShouldEqual, 5)
 				So(a.String(), ShouldEqual, "hello")
 				So(b.WrittenBytes, ShouldResemble, []byte("hello"))
@@ -72,7 +77,11 @@ func TestSplitWriter_Write(t *testing.T) {
 				test := spipe.NewSplitWriter(a, b, c)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldResemble, a.WriteErrors[0])
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role: spipe.RolePrimary,
+					Op:   spipe.OpWrite,
+					Err:  a.WriteErrors[0],
+				})
 				So(n, ShouldEqual, 5)
 				So(a.WrittenBytes, ShouldResemble, []byte("hello"))
 				So(b.String(), ShouldEqual, "")
@@ -87,7 +96,11 @@ func TestSplitWriter_Write(t *testing.T) {
 				test := spipe.NewSplitWriter(a, b, c)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldResemble, a.WriteErrors[0])
+				So(err, ShouldResemble, &spipe.StreamError{
+					Role: spipe.RolePrimary,
+					Op:   spipe.OpWrite,
+					Err:  a.WriteErrors[0],
+				})
 				So(n, ShouldEqual, 5)
 				So(a.WrittenBytes, ShouldResemble, []byte("hello"))
 				So(b.String(), ShouldEqual, "")
@@ -103,7 +116,7 @@ func TestSplitWriter_Write(t *testing.T) {
 				test := spipe.NewSplitWriter(a, b)
 				n, err := test.Write([]byte("hello"))
 
-				So(err, ShouldEqual, io.ErrShortWrite)
+				So(errors.Is(err, io.ErrShortWrite), ShouldBeTrue)
 				So(n, ShouldEqual, 1)
 				So(b.WriteCalls, ShouldEqual, 0)
 			})
@@ -117,8 +130,13 @@ func TestSplitWriter_Write(t *testing.T) {
 					n, err := test.Write([]byte("hello"))
 
 					So(string(a.WrittenBytes), ShouldEqual, "hello")
-					So(n, ShouldEqual, 1)
-					So(err, ShouldEqual, io.ErrShortWrite)
+					So(n, ShouldEqual, 5)
+					So(err, ShouldResemble, &spipe.StreamError{
+						Role:  spipe.RoleSecondary,
+						Index: 1,
+						Op:    spipe.OpWrite,
+						Err:   io.ErrShortWrite,
+					})
 				})
 
 				Convey("with ignore errors", func() {
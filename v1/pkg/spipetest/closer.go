@@ -0,0 +1,59 @@
+package spipetest
+
+import (
+	"io"
+	"sync"
+)
+
+// Closer is a programmable io.Closer.
+//
+// Each call to Close follows the next entry of Steps, and calls past the end of
+// Steps follow Rest.
+type Closer struct {
+	Steps []Step
+	Rest  Step
+
+	mu     sync.Mutex
+	script script
+}
+
+// NewCloser returns a Closer whose calls follow the given steps.
+func NewCloser(steps ...Step) *Closer {
+	return &Closer{Steps: steps}
+}
+
+func (c *Closer) Close() error {
+	c.mu.Lock()
+	st := c.script.step(OpClose, c.Steps, c.Rest)
+	c.script.calls = append(c.script.calls, Call{Op: OpClose, Err: st.Err, Panicked: st.Panic != nil})
+	c.mu.Unlock()
+
+	wait(st)
+
+	if st.Panic != nil {
+		panic(st.Panic)
+	}
+
+	return st.Err
+}
+
+// Count returns the number of times Close has been called.
+func (c *Closer) Count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.script.count(OpClose)
+}
+
+// ReadCloser pairs a reader with a Closer, so any reader can be given
+// programmable Close behaviour.
+type ReadCloser struct {
+	io.Reader
+	*Closer
+}
+
+// WriteCloser pairs a writer with a Closer, so any writer can be given
+// programmable Close behaviour.
+type WriteCloser struct {
+	io.Writer
+	*Closer
+}
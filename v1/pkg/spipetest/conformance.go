@@ -0,0 +1,358 @@
+package spipetest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+)
+
+// The conformance checks below exercise the io.Reader, io.Writer and io.Closer
+// contracts in the manner of testing/iotest, along with the stricter rules the
+// spipe types follow:
+//
+//   - Read and Write never return a byte count below zero or above len(p).
+//   - A reader's io.EOF is sticky, and data returned alongside io.EOF counts.
+//   - A write that is short returns an error.
+//   - Close may be called more than once, and later calls return nil.
+//   - Read and Write fail after Close.
+//
+// Each check returns nil if the stream conforms, or a MultiError with one error
+// per failed rule.
+
+// errBoom is returned by the faulty streams the wrapper checks inject.
+var errBoom = errors.New("spipetest: injected failure")
+
+// maxStalls is the number of consecutive empty reads tolerated before a reader
+// is considered stuck.
+const maxStalls = 100
+
+// CheckReader checks that readers returned by newReader conform, and return
+// the given content.  A new reader is made for each check.
+func CheckReader(newReader func() io.Reader, content []byte) error {
+	errs := spipe.NewMultiErrorBuilder()
+
+	for _, size := range []int{1, 3, 512, len(content) + 1} {
+		got, err := readAll(newReader(), size)
+		errs.Add(checkContent(fmt.Sprintf("read with %d byte buffer", size), got, err, content))
+	}
+
+	r := newReader()
+	if n, err := r.Read(nil); n != 0 {
+		errs.Add(fmt.Errorf("zero length read: returned n = %d, err = %v", n, err))
+	} else {
+		got, err := readAll(r, 512)
+		errs.Add(checkContent("read after zero length read", got, err, content))
+	}
+
+	return errs.Build()
+}
+
+// CheckReadCloser checks that readers returned by newReader conform, return the
+// given content and can be closed.
+func CheckReadCloser(newReader func() io.ReadCloser, content []byte) error {
+	errs := spipe.NewMultiErrorBuilder()
+
+	errs.Add(CheckReader(func() io.Reader { return newReader() }, content))
+
+	r := newReader()
+	_, _ = readAll(r, 512)
+	errs.Add(checkCloseTwice(r))
+
+	if n, err := r.Read(make([]byte, 8)); n != 0 || err == nil || err == io.EOF {
+		errs.Add(fmt.Errorf("read after close: returned n = %d, err = %v, want an error other than io.EOF", n, err))
+	}
+
+	errs.Add(checkCloseTwice(newReader()))
+
+	return errs.Build()
+}
+
+// CheckWriter checks that writers returned by newWriter conform.
+//
+// The written function returns the bytes the writer has passed on, and is
+// called once every write has been made.  Writers that hold data back, such as
+// record writers, should flush it first.  If written is nil, the content is not
+// checked.
+func CheckWriter(newWriter func() (w io.Writer, written func() []byte)) error {
+	w, written := newWriter()
+	return checkWrites(w, written, nil)
+}
+
+// CheckWriteCloser checks that writers returned by newWriter conform and can
+// be closed.
+//
+// The written function returns the bytes the writer has passed on, and is
+// called after the writer is closed.  If it is nil, the content is not checked.
+func CheckWriteCloser(newWriter func() (w io.WriteCloser, written func() []byte)) error {
+	errs := spipe.NewMultiErrorBuilder()
+
+	w, written := newWriter()
+	errs.Add(checkWrites(w, written, func() error { return checkCloseTwice(w) }))
+
+	if n, err := w.Write([]byte("late")); n != 0 || err == nil {
+		errs.Add(fmt.Errorf("write after close: returned n = %d, err = %v, want an error", n, err))
+	}
+
+	w, _ = newWriter()
+	errs.Add(checkCloseTwice(w))
+
+	return errs.Build()
+}
+
+// checkWrites makes writes of varied sizes, calls finish if it is not nil, and
+// then checks the written content.
+func checkWrites(w io.Writer, written func() []byte, finish func() error) error {
+	errs := spipe.NewMultiErrorBuilder()
+	sizes := []int{0, 1, 7, 100, 1000, 2991}
+
+	total := 0
+	for _, size := range sizes {
+		total += size
+	}
+
+	content := pattern(total)
+	rest := content
+
+	for _, size := range sizes {
+		buf := append([]byte(nil), rest[:size]...)
+		rest = rest[size:]
+
+		n, err := w.Write(buf)
+		if n != len(buf) || err != nil {
+			errs.Add(fmt.Errorf("write of %d bytes: returned n = %d, err = %v", size, n, err))
+		}
+
+		// The writer must not hold on to the caller's buffer.
+		for i := range buf {
+			buf[i] = 'X'
+		}
+	}
+
+	if finish != nil {
+		errs.Add(finish())
+	}
+
+	if written != nil {
+		if got := written(); !bytes.Equal(got, content) {
+			errs.Add(fmt.Errorf("written content: got %d bytes, want %d bytes: %s",
+				len(got), len(content), Identical(bytesOutput(content), bytesOutput(got))))
+		}
+	}
+
+	return errs.Build()
+}
+
+// CheckWriterWrapper checks that writers built by wrap on top of an underlying
+// writer report the underlying writer's failures.
+//
+// Failures may be reported by Write or, for writers that buffer, by Close.
+// The wrapper need not close the underlying writer, but must not close it more
+// than once and must report its Close error if it does close it.
+func CheckWriterWrapper(wrap func(out io.WriteCloser) io.WriteCloser) error {
+	errs := spipe.NewMultiErrorBuilder()
+	content := pattern(64)
+
+	faults := []struct {
+		name string
+		out  *Writer
+		is   error
+	}{
+		{"short write", &Writer{Rest: Step{Max: 1}}, nil},
+		{"write with no progress", &Writer{Rest: Step{None: true}}, nil},
+		{"failed write", &Writer{Rest: Step{Err: errBoom}}, errBoom},
+		{"failed write after short write", &Writer{Steps: []Step{{Max: 3}}, Rest: Step{Err: errBoom}}, nil},
+		{"failed close", &Writer{CloseRest: Step{Err: errBoom}}, errBoom},
+	}
+
+	for _, f := range faults {
+		w := wrap(f.out)
+
+		n, werr := w.Write(content)
+		if n < 0 || n > len(content) {
+			errs.Add(fmt.Errorf("%s: write returned n = %d for %d bytes", f.name, n, len(content)))
+		}
+
+		if werr == nil && n < len(content) {
+			errs.Add(fmt.Errorf("%s: write returned n = %d for %d bytes without an error", f.name, n, len(content)))
+		}
+
+		cerr := w.Close()
+		closes := f.out.Count(OpClose)
+
+		if closes > 1 {
+			errs.Add(fmt.Errorf("%s: underlying writer closed %d times", f.name, closes))
+		}
+
+		if f.name == "failed close" && closes == 0 {
+			continue
+		}
+
+		if werr == nil && cerr == nil {
+			errs.Add(fmt.Errorf("%s: not reported by Write or Close", f.name))
+		} else if f.is != nil && !errors.Is(werr, f.is) && !errors.Is(cerr, f.is) {
+			errs.Add(fmt.Errorf("%s: reported as %v, %v, want an error wrapping %v", f.name, werr, cerr, f.is))
+		}
+	}
+
+	return errs.Build()
+}
+
+// CheckReaderWrapper checks that readers built by wrap on top of an underlying
+// reader pass the underlying reader's content through unchanged, and report its
+// failures.
+//
+// If the wrapper is an io.Closer, closing it must not close the underlying
+// reader more than once and must report its Close error if it does close it.
+func CheckReaderWrapper(wrap func(in io.ReadCloser) io.Reader) error {
+	errs := spipe.NewMultiErrorBuilder()
+	content := pattern(64)
+
+	// Sources that return data alongside io.EOF do so on their first read, and
+	// that data is all the wrapper may pass on; the rest of their content must
+	// not be read.
+	sources := []struct {
+		name string
+		in   *Reader
+		want []byte
+	}{
+		{"plain reads", &Reader{}, content},
+		{"data with EOF", &Reader{Rest: Step{Err: io.EOF}}, content[:7]},
+		{"short reads", &Reader{Rest: Step{Max: 1}}, content},
+		{"short reads with EOF", &Reader{Rest: Step{Max: 5, Err: io.EOF}}, content[:5]},
+		{"empty reads", &Reader{Steps: []Step{{None: true}, {None: true}, {Max: 10}, {None: true}}}, content},
+		{"failed close", &Reader{CloseRest: Step{Err: errBoom}}, content},
+	}
+
+	for _, s := range sources {
+		s.in.Data = content
+
+		r := wrap(s.in)
+		got, err := readAll(r, 7)
+
+		// A wrapper that closes its input once it is consumed reports the
+		// input's Close error from Read.
+		if errors.Is(err, s.in.CloseRest.Err) && s.in.Count(OpClose) == 1 {
+			err = nil
+		}
+
+		errs.Add(checkContent(s.name, got, err, s.want))
+		errs.Add(checkWrapperClose(s.name, r, s.in))
+	}
+
+	in := &Reader{Data: content, Steps: []Step{{Max: 2}, {Max: 1, Err: errBoom}}}
+	r := wrap(in)
+	got, err := readAll(r, 7)
+
+	if !bytes.Equal(got, content[:3]) || !errors.Is(err, errBoom) {
+		errs.Add(fmt.Errorf("failed read: got %q, %v, want %q and an error wrapping %v", got, err, content[:3], errBoom))
+	}
+
+	errs.Add(checkWrapperClose("failed read", r, in))
+
+	return errs.Build()
+}
+
+// readAll reads r to EOF using a buffer of the given size, checking the
+// returned byte counts and that EOF is sticky.
+func readAll(r io.Reader, size int) ([]byte, error) {
+	var out []byte
+	buf := make([]byte, size)
+	stalls := 0
+
+	for {
+		n, err := r.Read(buf)
+
+		if n < 0 || n > len(buf) {
+			return out, fmt.Errorf("read returned n = %d for a %d byte buffer", n, len(buf))
+		}
+
+		out = append(out, buf[:n]...)
+
+		if err == io.EOF {
+			if n, err = r.Read(buf); n != 0 || err != io.EOF {
+				return out, fmt.Errorf("read after io.EOF returned n = %d, err = %v", n, err)
+			}
+
+			return out, nil
+		}
+
+		if err != nil {
+			return out, err
+		}
+
+		if n == 0 {
+			if stalls++; stalls > maxStalls {
+				return out, fmt.Errorf("%d reads in a row returned no data and no error", stalls)
+			}
+		} else {
+			stalls = 0
+		}
+	}
+}
+
+func checkContent(name string, got []byte, err error, want []byte) error {
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%s: got %d bytes, want %d bytes: %s",
+			name, len(got), len(want), Identical(bytesOutput(want), bytesOutput(got)))
+	}
+
+	return nil
+}
+
+func checkCloseTwice(c io.Closer) error {
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("close: %w", err)
+	}
+
+	if err := c.Close(); err != nil {
+		return fmt.Errorf("second close: returned %v, want nil", err)
+	}
+
+	return nil
+}
+
+func checkWrapperClose(name string, r io.Reader, in *Reader) error {
+	c, ok := r.(io.Closer)
+	if !ok {
+		return nil
+	}
+
+	before := in.Count(OpClose)
+	err := c.Close()
+	_ = c.Close()
+
+	closes := in.Count(OpClose)
+
+	if closes > 1 {
+		return fmt.Errorf("%s: underlying reader closed %d times", name, closes)
+	}
+
+	if before == 0 && closes == 1 && in.CloseRest.Err != nil && !errors.Is(err, in.CloseRest.Err) {
+		return fmt.Errorf("%s: close returned %v, want an error wrapping %v", name, err, in.CloseRest.Err)
+	}
+
+	return nil
+}
+
+// pattern returns n bytes of varied content.
+func pattern(n int) []byte {
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte('a' + i%23)
+	}
+
+	return out
+}
+
+type bytesOutput []byte
+
+func (b bytesOutput) Bytes() []byte {
+	return b
+}
@@ -0,0 +1,94 @@
+package spipetest_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipetest"
+)
+
+func TestCheckReader(t *testing.T) {
+	Convey("CheckReader", t, func() {
+		content := []byte("hello world")
+
+		So(spipetest.CheckReader(func() io.Reader { return bytes.NewReader(content) }, content), ShouldBeNil)
+
+		err := spipetest.CheckReader(func() io.Reader { return bytes.NewReader(content[:5]) }, content)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "read with 1 byte buffer: got 5 bytes, want 11 bytes")
+
+		Convey("CheckReadCloser", func() {
+			So(spipetest.CheckReadCloser(func() io.ReadCloser {
+				return spipe.NewMultiReadCloser(ioutil.NopCloser(bytes.NewReader(content)))
+			}, content), ShouldBeNil)
+
+			err := spipetest.CheckReadCloser(func() io.ReadCloser {
+				return ioutil.NopCloser(bytes.NewReader(content))
+			}, content)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "read after close")
+		})
+	})
+}
+
+func TestCheckWriteCloser(t *testing.T) {
+	Convey("CheckWriteCloser", t, func() {
+		So(spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+			out := new(spipetest.Writer)
+			return spipe.NewAsyncWriteCloser(out), out.Bytes
+		}), ShouldBeNil)
+
+		err := spipetest.CheckWriteCloser(func() (io.WriteCloser, func() []byte) {
+			out := new(spipetest.Writer)
+			return out, out.Bytes
+		})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "write after close")
+
+		Convey("CheckWriter", func() {
+			So(spipetest.CheckWriter(func() (io.Writer, func() []byte) {
+				out := new(bytes.Buffer)
+				return out, out.Bytes
+			}), ShouldBeNil)
+
+			err := spipetest.CheckWriter(func() (io.Writer, func() []byte) {
+				return new(bytes.Buffer), func() []byte { return nil }
+			})
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldContainSubstring, "written content")
+		})
+	})
+}
+
+func TestCheckWrapper(t *testing.T) {
+	Convey("CheckWriterWrapper", t, func() {
+		So(spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+			return spipe.NewSplitWriteCloser(out)
+		}), ShouldBeNil)
+
+		// Passing the writer through as is lets short writes go unreported.
+		err := spipetest.CheckWriterWrapper(func(out io.WriteCloser) io.WriteCloser {
+			return out
+		})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "short write: not reported by Write or Close")
+	})
+
+	Convey("CheckReaderWrapper", t, func() {
+		So(spipetest.CheckReaderWrapper(func(in io.ReadCloser) io.Reader {
+			return io.MultiReader(in)
+		}), ShouldBeNil)
+
+		// Passing the reader through as is reads on past an early io.EOF.
+		err := spipetest.CheckReaderWrapper(func(in io.ReadCloser) io.Reader {
+			return in
+		})
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "data with EOF")
+	})
+}
@@ -0,0 +1,56 @@
+package spipetest
+
+import (
+	"fmt"
+	"testing"
+)
+
+// Output is anything that can report the bytes written to it, such as a Writer
+// or a *bytes.Buffer.
+type Output interface {
+	Bytes() []byte
+}
+
+// Identical returns an error describing the first difference between the bytes
+// held by the given outputs, or nil if every output holds the same bytes.
+//
+// Every output is compared against the first.
+func Identical(outputs ...Output) error {
+	if len(outputs) < 2 {
+		return nil
+	}
+
+	want := outputs[0].Bytes()
+
+	for i, out := range outputs[1:] {
+		got := out.Bytes()
+
+		n := len(got)
+		if len(want) < n {
+			n = len(want)
+		}
+
+		for j := 0; j < n; j++ {
+			if got[j] != want[j] {
+				return fmt.Errorf("output %d differs from output 0 at byte %d: got %#02x, want %#02x",
+					i+1, j, got[j], want[j])
+			}
+		}
+
+		if len(got) != len(want) {
+			return fmt.Errorf("output %d holds %d bytes, output 0 holds %d", i+1, len(got), len(want))
+		}
+	}
+
+	return nil
+}
+
+// AssertIdentical fails the test if the given outputs do not all hold the same
+// bytes, such as the outputs of a split writer.
+func AssertIdentical(t testing.TB, outputs ...Output) {
+	t.Helper()
+
+	if err := Identical(outputs...); err != nil {
+		t.Error(err)
+	}
+}
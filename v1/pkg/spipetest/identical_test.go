@@ -0,0 +1,50 @@
+package spipetest_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipetest"
+)
+
+// recordingTB records the errors reported through it.
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Error(args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprint(args...))
+}
+
+func TestIdentical(t *testing.T) {
+	Convey("Identical", t, func() {
+		a, b := bytes.NewBufferString("hello"), bytes.NewBufferString("hello")
+
+		So(spipetest.Identical(), ShouldBeNil)
+		So(spipetest.Identical(a), ShouldBeNil)
+		So(spipetest.Identical(a, b), ShouldBeNil)
+
+		err := spipetest.Identical(a, b, bytes.NewBufferString("help"))
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "output 2 differs from output 0 at byte 3: got 0x70, want 0x6c")
+
+		err = spipetest.Identical(a, bytes.NewBufferString("hello!"))
+		So(err.Error(), ShouldEqual, "output 1 holds 6 bytes, output 0 holds 5")
+
+		Convey("AssertIdentical", func() {
+			tb := new(recordingTB)
+
+			spipetest.AssertIdentical(tb, a, b)
+			So(tb.errors, ShouldBeEmpty)
+
+			spipetest.AssertIdentical(tb, a, new(bytes.Buffer))
+			So(tb.errors, ShouldResemble, []string{"output 1 holds 0 bytes, output 0 holds 5"})
+		})
+	})
+}
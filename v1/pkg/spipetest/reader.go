@@ -0,0 +1,93 @@
+package spipetest
+
+import (
+	"io"
+	"sync"
+)
+
+// Reader is a programmable io.ReadCloser that returns Data.
+//
+// Each call to Read follows the next entry of Steps, and calls past the end of
+// Steps follow Rest.  A call that has no data left to return and no scripted
+// error returns io.EOF.  A step's Err is returned alongside any bytes read, so
+// a final read can return data with io.EOF, and an early io.EOF truncates the
+// stream.  Close follows CloseSteps and CloseRest in the same way.
+//
+// A Reader may be used from several goroutines.  Fields must not be changed
+// once the Reader is in use.
+type Reader struct {
+	Data       []byte
+	Steps      []Step
+	Rest       Step
+	CloseSteps []Step
+	CloseRest  Step
+
+	mu     sync.Mutex
+	pos    int
+	script script
+}
+
+// NewReader returns a Reader that returns the given data, following the given
+// steps.
+func NewReader(data string, steps ...Step) *Reader {
+	return &Reader{Data: []byte(data), Steps: steps}
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	r.mu.Lock()
+	st := r.script.step(OpRead, r.Steps, r.Rest)
+	n := copy(p[:limit(st, len(p))], r.Data[r.pos:])
+	r.pos += n
+
+	err := st.Err
+	if err == nil && n == 0 && len(p) > 0 && r.pos == len(r.Data) {
+		err = io.EOF
+	}
+
+	r.script.calls = append(r.script.calls, Call{Op: OpRead, Size: len(p), N: n, Err: err, Panicked: st.Panic != nil})
+	r.mu.Unlock()
+
+	wait(st)
+
+	if st.Panic != nil {
+		panic(st.Panic)
+	}
+
+	return n, err
+}
+
+func (r *Reader) Close() error {
+	r.mu.Lock()
+	st := r.script.step(OpClose, r.CloseSteps, r.CloseRest)
+	r.script.calls = append(r.script.calls, Call{Op: OpClose, Err: st.Err, Panicked: st.Panic != nil})
+	r.mu.Unlock()
+
+	wait(st)
+
+	if st.Panic != nil {
+		panic(st.Panic)
+	}
+
+	return st.Err
+}
+
+// Remaining returns the number of bytes of Data not yet read.
+func (r *Reader) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.Data) - r.pos
+}
+
+// Calls returns every call made so far, in order.
+func (r *Reader) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.script.calls...)
+}
+
+// Count returns the number of calls made so far to the given method.
+func (r *Reader) Count(op string) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.script.count(op)
+}
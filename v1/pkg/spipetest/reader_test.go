@@ -0,0 +1,89 @@
+package spipetest_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipetest"
+)
+
+func TestReader(t *testing.T) {
+	Convey("Reader", t, func() {
+		Convey("plain", func() {
+			test := spipetest.NewReader("hello")
+
+			out, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "hello")
+			So(test.Remaining(), ShouldEqual, 0)
+			So(test.Close(), ShouldBeNil)
+		})
+
+		Convey("data with EOF", func() {
+			test := spipetest.NewReader("hello", spipetest.Step{Err: io.EOF})
+
+			buf := make([]byte, 10)
+			n, err := test.Read(buf)
+			So(n, ShouldEqual, 5)
+			So(err, ShouldEqual, io.EOF)
+		})
+
+		Convey("short reads and errors", func() {
+			boom := errors.New("boom")
+			test := spipetest.NewReader("hello",
+				spipetest.Step{Max: 2},
+				spipetest.Step{None: true},
+				spipetest.Step{Max: 1, Err: boom},
+			)
+
+			buf := make([]byte, 10)
+
+			n, err := test.Read(buf)
+			So(n, ShouldEqual, 2)
+			So(err, ShouldBeNil)
+
+			n, err = test.Read(buf)
+			So(n, ShouldEqual, 0)
+			So(err, ShouldBeNil)
+
+			n, err = test.Read(buf)
+			So(n, ShouldEqual, 1)
+			So(err, ShouldEqual, boom)
+			So(test.Remaining(), ShouldEqual, 2)
+
+			So(test.Calls()[2], ShouldResemble, spipetest.Call{Op: spipetest.OpRead, Size: 10, N: 1, Err: boom})
+		})
+
+		Convey("truncated", func() {
+			test := spipetest.NewReader("hello", spipetest.At(2, spipetest.Step{None: true, Err: io.EOF})...)
+			test.Steps[0].Max = 3
+
+			out, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "hel")
+		})
+
+		Convey("as multi-reader inputs", func() {
+			boom := errors.New("boom")
+			closer := spipetest.NewCloser(spipetest.Step{Err: boom})
+
+			test := spipe.NewMultiReadCloser(
+				spipetest.NewReader("one", spipetest.Step{Max: 1}),
+				spipetest.ReadCloser{Reader: spipetest.NewReader("two"), Closer: closer},
+			)
+
+			out, err := ioutil.ReadAll(test)
+			So(err, ShouldBeNil)
+			So(string(out), ShouldEqual, "onetwo")
+
+			err = test.Close()
+			So(errors.Is(err, boom), ShouldBeTrue)
+			So(closer.Count(), ShouldEqual, 1)
+		})
+	})
+}
@@ -0,0 +1,128 @@
+// Package spipetest provides programmable streams for testing code built on
+// spipe.
+//
+// Writer, Reader and Closer follow a script of Steps, one per call, that can
+// return errors, short counts, delay, block until released or panic.  Every
+// call is recorded so tests can check what the code under test did.
+//
+// The Check functions form a conformance suite for readers and writers, such as
+// custom inputs and outputs given to the spipe types.
+package spipetest
+
+import (
+	"time"
+)
+
+// Operation names recorded in Call values.
+const (
+	OpWrite = "Write"
+	OpRead  = "Read"
+	OpClose = "Close"
+)
+
+// Step describes how a programmable stream responds to a single call.  The
+// zero value is a call that succeeds in full.
+type Step struct {
+	// Err is returned from the call, alongside any bytes transferred.
+	Err error
+
+	// Max, if greater than zero, caps the number of bytes the call transfers,
+	// producing a short write or read.
+	Max int
+
+	// None makes the call transfer no bytes at all.
+	None bool
+
+	// Delay is slept before the call returns.
+	Delay time.Duration
+
+	// Block, if not nil, makes the call wait until the channel is closed or
+	// receives a value.
+	Block <-chan struct{}
+
+	// Panic, if not nil, makes the call panic with the value after it has been
+	// recorded.
+	Panic interface{}
+}
+
+// At returns a script whose nth call, counting from 1, follows the given step
+// and whose earlier calls succeed.
+func At(n int, s Step) []Step {
+	if n < 1 {
+		n = 1
+	}
+
+	out := make([]Step, n)
+	out[n-1] = s
+
+	return out
+}
+
+// Call records a single call made to a programmable stream.
+type Call struct {
+	// Op is the method that was called.
+	Op string
+
+	// Size is the length of the buffer given to the call.
+	Size int
+
+	// N is the byte count the call returned.
+	N int
+
+	// Err is the error the call returned.
+	Err error
+
+	// Panicked is set if the call panicked.
+	Panicked bool
+}
+
+// script is the state shared by every programmable stream.
+type script struct {
+	calls []Call
+}
+
+// step returns the step the next call of the given op follows.
+func (s *script) step(op string, steps []Step, rest Step) Step {
+	n := s.count(op)
+
+	if n < len(steps) {
+		return steps[n]
+	}
+
+	return rest
+}
+
+func (s *script) count(op string) (n int) {
+	for _, c := range s.calls {
+		if c.Op == op {
+			n++
+		}
+	}
+
+	return
+}
+
+// limit returns the number of bytes a call following the given step transfers
+// when size bytes are available.
+func limit(st Step, size int) int {
+	if st.None {
+		return 0
+	}
+
+	if st.Max > 0 && st.Max < size {
+		return st.Max
+	}
+
+	return size
+}
+
+// wait blocks and sleeps as the given step requires.
+func wait(st Step) {
+	if st.Block != nil {
+		<-st.Block
+	}
+
+	if st.Delay > 0 {
+		time.Sleep(st.Delay)
+	}
+}
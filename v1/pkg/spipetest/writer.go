@@ -0,0 +1,87 @@
+package spipetest
+
+import (
+	"sync"
+)
+
+// Writer is a programmable io.WriteCloser.
+//
+// Each call to Write follows the next entry of Steps, and calls past the end of
+// Steps follow Rest.  Only the bytes a call reports as written are kept.  Close
+// follows CloseSteps and CloseRest in the same way.
+//
+// A Writer may be used from several goroutines.  Fields must not be changed
+// once the Writer is in use.
+type Writer struct {
+	Steps      []Step
+	Rest       Step
+	CloseSteps []Step
+	CloseRest  Step
+
+	mu      sync.Mutex
+	written []byte
+	script  script
+}
+
+// NewWriter returns a Writer whose writes follow the given steps.
+func NewWriter(steps ...Step) *Writer {
+	return &Writer{Steps: steps}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	st := w.script.step(OpWrite, w.Steps, w.Rest)
+	n := limit(st, len(p))
+	w.written = append(w.written, p[:n]...)
+	w.script.calls = append(w.script.calls, Call{Op: OpWrite, Size: len(p), N: n, Err: st.Err, Panicked: st.Panic != nil})
+	w.mu.Unlock()
+
+	wait(st)
+
+	if st.Panic != nil {
+		panic(st.Panic)
+	}
+
+	return n, st.Err
+}
+
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	st := w.script.step(OpClose, w.CloseSteps, w.CloseRest)
+	w.script.calls = append(w.script.calls, Call{Op: OpClose, Err: st.Err, Panicked: st.Panic != nil})
+	w.mu.Unlock()
+
+	wait(st)
+
+	if st.Panic != nil {
+		panic(st.Panic)
+	}
+
+	return st.Err
+}
+
+// Bytes returns a copy of the bytes written so far.
+func (w *Writer) Bytes() []byte {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]byte(nil), w.written...)
+}
+
+// String returns the bytes written so far as a string.
+func (w *Writer) String() string {
+	return string(w.Bytes())
+}
+
+// Calls returns every call made so far, in order.
+func (w *Writer) Calls() []Call {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]Call(nil), w.script.calls...)
+}
+
+// Count returns the number of calls made so far to the given method.
+func (w *Writer) Count(op string) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.script.count(op)
+}
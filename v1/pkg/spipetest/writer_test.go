@@ -0,0 +1,124 @@
+package spipetest_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipe"
+	"github.com/vulpine-io/split-pipe/v1/pkg/spipetest"
+)
+
+func TestWriter(t *testing.T) {
+	Convey("Writer", t, func() {
+		Convey("zero value", func() {
+			test := new(spipetest.Writer)
+
+			n, err := test.Write([]byte("hello"))
+			So(n, ShouldEqual, 5)
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+			So(test.String(), ShouldEqual, "hello")
+			So(test.Calls(), ShouldResemble, []spipetest.Call{
+				{Op: spipetest.OpWrite, Size: 5, N: 5},
+				{Op: spipetest.OpClose},
+			})
+		})
+
+		Convey("scripted", func() {
+			boom := errors.New("boom")
+			test := spipetest.NewWriter(
+				spipetest.Step{Max: 2},
+				spipetest.Step{None: true},
+				spipetest.Step{Err: boom, Max: 1},
+			)
+			test.Rest = spipetest.Step{Err: io.ErrClosedPipe}
+			test.CloseSteps = []spipetest.Step{{Err: boom}}
+
+			n, err := test.Write([]byte("abc"))
+			So(n, ShouldEqual, 2)
+			So(err, ShouldBeNil)
+
+			n, err = test.Write([]byte("abc"))
+			So(n, ShouldEqual, 0)
+			So(err, ShouldBeNil)
+
+			n, err = test.Write([]byte("abc"))
+			So(n, ShouldEqual, 1)
+			So(err, ShouldEqual, boom)
+
+			_, err = test.Write([]byte("abc"))
+			So(err, ShouldEqual, io.ErrClosedPipe)
+			_, err = test.Write([]byte("abc"))
+			So(err, ShouldEqual, io.ErrClosedPipe)
+
+			So(test.String(), ShouldEqual, "aba"+"abcabc")
+			So(test.Close(), ShouldEqual, boom)
+			So(test.Close(), ShouldBeNil)
+			So(test.Count(spipetest.OpWrite), ShouldEqual, 5)
+			So(test.Count(spipetest.OpClose), ShouldEqual, 2)
+		})
+
+		Convey("at", func() {
+			boom := errors.New("boom")
+			test := &spipetest.Writer{Steps: spipetest.At(3, spipetest.Step{Err: boom})}
+
+			for i := 0; i < 2; i++ {
+				_, err := test.Write([]byte("a"))
+				So(err, ShouldBeNil)
+			}
+
+			_, err := test.Write([]byte("a"))
+			So(err, ShouldEqual, boom)
+		})
+
+		Convey("block and delay", func() {
+			release := make(chan struct{})
+			test := spipetest.NewWriter(spipetest.Step{Block: release, Delay: time.Millisecond})
+
+			done := make(chan struct{})
+			go func() {
+				_, _ = test.Write([]byte("a"))
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				t.Fatal("write returned before release")
+			case <-time.After(10 * time.Millisecond):
+			}
+
+			close(release)
+			<-done
+
+			So(test.String(), ShouldEqual, "a")
+		})
+
+		Convey("panic", func() {
+			test := spipetest.NewWriter(spipetest.Step{Panic: "hiya!"})
+
+			So(func() { _, _ = test.Write([]byte("a")) }, ShouldPanicWith, "hiya!")
+			So(test.Calls()[0].Panicked, ShouldBeTrue)
+		})
+
+		Convey("as split writer outputs", func() {
+			primary, secondary := new(spipetest.Writer), new(spipetest.Writer)
+			failing := &spipetest.Writer{Rest: spipetest.Step{Err: io.ErrClosedPipe}}
+
+			test := spipe.NewSplitWriteCloser(primary, secondary, failing).IgnoreErrors(true)
+
+			_, err := test.Write([]byte("hello"))
+			So(err, ShouldBeNil)
+			_, err = test.WriteString(" world")
+			So(err, ShouldBeNil)
+			So(test.Close(), ShouldBeNil)
+
+			spipetest.AssertIdentical(t, primary, secondary, failing)
+			So(primary.Count(spipetest.OpClose), ShouldEqual, 1)
+			So(failing.Count(spipetest.OpWrite), ShouldEqual, 2)
+		})
+	})
+}